@@ -0,0 +1,174 @@
+package slicer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxErrorBodySize is how much of an error response body is kept in
+// APIError.Body unless the client is created with WithMaxErrorBodySize.
+const DefaultMaxErrorBodySize = 4 << 10
+
+// APIError is returned, wrapped, when the API answers with an unexpected
+// status code. It keeps the status and response body so callers can show the
+// server's message, and unwraps to ErrUnauthorized for 401 and ErrForbidden
+// for 403 so authentication failures can be told apart with errors.Is.
+//
+// Every method formats it the same way, as "<status>: <body>" with
+// surrounding whitespace trimmed from the body, behind a prefix naming the
+// failed operation, e.g. "API request failed: 404 Not Found: no such VM":
+//
+//	var apiErr *slicer.APIError
+//	if errors.As(err, &apiErr) {
+//		fmt.Println(apiErr.StatusCode, apiErr.Body)
+//	}
+type APIError struct {
+	StatusCode int
+	Status     string
+	// ContentType is the Content-Type of the response, which is not
+	// necessarily JSON when the error came from a proxy in front of the API.
+	ContentType string
+	Body        string
+	// Truncated is true when Body holds only the start of a response
+	// larger than the client's limit, see WithMaxErrorBodySize.
+	Truncated bool
+}
+
+func newAPIError(res *http.Response, body []byte) *APIError {
+	limit := DefaultMaxErrorBodySize
+	if res.Request != nil {
+		if n, ok := res.Request.Context().Value(errorBodyLimitKey{}).(int); ok {
+			limit = n
+		}
+	}
+
+	body = bytes.TrimSpace(body)
+	truncated := false
+	if limit >= 0 && len(body) > limit {
+		// Cut on a rune boundary so the kept prefix stays valid UTF-8.
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = bytes.TrimRightFunc(body[:cut], unicode.IsSpace)
+		truncated = true
+	}
+
+	return &APIError{
+		StatusCode:  res.StatusCode,
+		Status:      res.Status,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        string(body),
+		Truncated:   truncated,
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return e.Status
+	}
+	if e.Truncated {
+		return e.Status + ": " + e.Body + " ... [truncated]"
+	}
+	return e.Status + ": " + e.Body
+}
+
+// Unwrap returns the sentinel error matching the status code, if any.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
+
+// decodeJSONBody decodes a successful response body into v. When the body is
+// not valid JSON and the response declares a non-JSON Content-Type, such as
+// an HTML page from a proxy, the raw body is returned in an APIError rather
+// than as a cryptic decode error.
+func decodeJSONBody(res *http.Response, body []byte, v any) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return emptyResponseError(res)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		if ct := res.Header.Get("Content-Type"); ct != "" && !isJSONContentType(ct) {
+			return fmt.Errorf("unexpected response content type %q: %w", ct, newAPIError(res, body))
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// decodeJSONResponse decodes a successful response body into v as it is
+// read, returning an error wrapping ErrEmptyResponse if there is no body.
+func decodeJSONResponse(res *http.Response, v any) error {
+	if res.Body == nil {
+		return emptyResponseError(res)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return emptyResponseError(res)
+		}
+		return err
+	}
+	return nil
+}
+
+// emptyResponseError reports a missing body, naming the endpoint.
+func emptyResponseError(res *http.Response) error {
+	if res.Request != nil && res.Request.URL != nil {
+		return fmt.Errorf("%s %s: %w", res.Request.Method, res.Request.URL.Path, ErrEmptyResponse)
+	}
+	return ErrEmptyResponse
+}
+
+// isJSONContentType reports whether ct is application/json or a +json type.
+func isJSONContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// errorBodyLimitKey carries the client's error body limit from do to
+// newAPIError on the request context.
+type errorBodyLimitKey struct{}
+
+// do sends req with the client's HTTP client. Error response bodies are cut
+// off just past the limit set by WithMaxErrorBodySize, or
+// DefaultMaxErrorBodySize, so a huge page from a proxy is neither buffered
+// nor drained in full.
+func (c *SlicerClient) do(req *http.Request) (*http.Response, error) {
+	limit := c.maxErrorBody
+	if limit == 0 {
+		limit = DefaultMaxErrorBodySize
+	}
+	req = req.WithContext(context.WithValue(req.Context(), errorBodyLimitKey{}, limit))
+
+	res, err := c.httpClient.Do(req)
+	if err != nil || res.Body == nil {
+		return res, err
+	}
+	if res.StatusCode >= http.StatusBadRequest && limit >= 0 {
+		res.Body = &limitedBody{Reader: io.LimitReader(res.Body, int64(limit)+1), Closer: res.Body}
+	}
+	return res, nil
+}
+
+// limitedBody reads from a limited view of a body and closes the original.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
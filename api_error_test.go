@@ -0,0 +1,146 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIError_ConsistentFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, "  boom\n")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{
+			name: "typed GET",
+			call: func() error { _, err := client.GetHostGroups(ctx); return err },
+			want: "API request failed: 500 Internal Server Error: boom",
+		},
+		{
+			name: "VM lifecycle",
+			call: func() error { return client.PauseVM(ctx, "vm-1") },
+			want: "API request failed: 500 Internal Server Error: boom",
+		},
+		{
+			name: "JSON request",
+			call: func() error { return client.DeleteSecret(ctx, "s") },
+			want: "API request failed: 500 Internal Server Error: boom",
+		},
+		{
+			name: "background exec",
+			call: func() error { _, err := client.ExecInfo(ctx, "vm-1", "id"); return err },
+			want: "slicer: ExecInfo: 500 Internal Server Error: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("Want %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEmptyResponseBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"buffered": func() error { _, err := client.GetHostGroups(ctx); return err },
+		"streamed": func() error { _, err := client.ExecInfo(ctx, "vm-1", "id"); return err },
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			err := call()
+			if !errors.Is(err, ErrEmptyResponse) {
+				t.Fatalf("Want ErrEmptyResponse, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "GET /") {
+				t.Fatalf("Want the endpoint in the error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAPIError_BodyIsCapped(t *testing.T) {
+	page := strings.Repeat("<p>proxy error</p>", 1<<16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(w, page)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want int
+	}{
+		{name: "default", want: DefaultMaxErrorBodySize},
+		{name: "custom", opts: []ClientOption{WithMaxErrorBodySize(100)}, want: 100},
+		{name: "unlimited", opts: []ClientOption{WithMaxErrorBodySize(-1)}, want: len(page)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read int64
+			httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				res, err := http.DefaultTransport.RoundTrip(req)
+				if err == nil {
+					res.Body = &countingBody{ReadCloser: res.Body, n: &read}
+				}
+				return res, err
+			})}
+			client := NewSlicerClient(server.URL, "token", "test-agent", httpClient, tt.opts...)
+			_, err := client.GetHostGroups(ctx)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Want an APIError, got %v", err)
+			}
+			if len(apiErr.Body) != tt.want || !strings.HasPrefix(page, apiErr.Body) {
+				t.Fatalf("Want the first %d bytes of the body, got %d", tt.want, len(apiErr.Body))
+			}
+			truncated := tt.want < len(page)
+			if apiErr.Truncated != truncated || strings.HasSuffix(err.Error(), "[truncated]") != truncated {
+				t.Fatalf("Want truncated=%v, got %v in %q", truncated, apiErr.Truncated, err.Error()[len(err.Error())-40:])
+			}
+			if truncated && read > int64(tt.want)+1 {
+				t.Fatalf("Want at most %d bytes read from the body, read %d", tt.want+1, read)
+			}
+		})
+	}
+}
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.n += int64(n)
+	return n, err
+}
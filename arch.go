@@ -0,0 +1,50 @@
+package slicer
+
+import "strings"
+
+// Arch is a CPU architecture in canonical form. The Arch fields of nodes,
+// host groups and snapshots are plain strings kept exactly as the server
+// reported them, and servers and users may spell the same architecture
+// differently, so compare parsed values:
+//
+//	if slicer.ParseArch(node.Arch) == slicer.ArchARM64 { ... }
+type Arch string
+
+// Architectures supported by Slicer.
+const (
+	ArchAMD64 Arch = "amd64"
+	ArchARM64 Arch = "arm64"
+)
+
+// archAliases maps common alternative spellings to their GOARCH name.
+var archAliases = map[string]Arch{
+	"amd64":   ArchAMD64,
+	"x86_64":  ArchAMD64,
+	"x86-64":  ArchAMD64,
+	"x64":     ArchAMD64,
+	"arm64":   ArchARM64,
+	"aarch64": ArchARM64,
+	"armv8":   ArchARM64,
+	"arm64v8": ArchARM64,
+}
+
+// ParseArch returns the Arch for s, accepting common variants such as
+// "x86_64" for ArchAMD64 and "aarch64" for ArchARM64, in any case.
+// Unrecognised values are returned trimmed and lower-cased.
+func ParseArch(s string) Arch {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if a, ok := archAliases[s]; ok {
+		return a
+	}
+	return Arch(s)
+}
+
+// Normalize returns a in its canonical form, see ParseArch.
+func (a Arch) Normalize() Arch {
+	return ParseArch(string(a))
+}
+
+// String returns a as a string.
+func (a Arch) String() string {
+	return string(a)
+}
@@ -0,0 +1,59 @@
+package slicer
+
+import (
+	"context"
+	"io"
+)
+
+// Media types of the copy endpoint's archive and binary modes.
+const (
+	ContentTypeTar    = "application/x-tar"
+	ContentTypeBinary = "application/octet-stream"
+)
+
+// Archiver packs and unpacks directory trees for the archive ("tar") copy
+// mode. Its ContentType is sent as Content-Type on uploads and Accept on
+// downloads, so agents that support other formats, such as zip or cpio,
+// can be asked for them. TarArchiver is used when none is set.
+type Archiver interface {
+	// ContentType is the media type of the archive, e.g. "application/x-tar".
+	ContentType() string
+	// Archive writes parentDir/baseName, and everything below it, to w.
+	Archive(ctx context.Context, w io.Writer, parentDir, baseName string, opts StreamTarOptions) error
+	// Extract unpacks the archive read from r into the directory dest.
+	Extract(ctx context.Context, r io.Reader, dest string, opts ExtractTarOptions) error
+}
+
+// TarArchiver is the default Archiver, using StreamTarArchiveWithOptions
+// and ExtractTarToPathWithOptions.
+type TarArchiver struct{}
+
+// ContentType returns ContentTypeTar.
+func (TarArchiver) ContentType() string {
+	return ContentTypeTar
+}
+
+// Archive streams a tar archive with StreamTarArchiveWithOptions.
+func (TarArchiver) Archive(ctx context.Context, w io.Writer, parentDir, baseName string, opts StreamTarOptions) error {
+	return StreamTarArchiveWithOptions(ctx, w, parentDir, baseName, opts)
+}
+
+// Extract unpacks a tar archive with ExtractTarToPathWithOptions.
+func (TarArchiver) Extract(ctx context.Context, r io.Reader, dest string, opts ExtractTarOptions) error {
+	return ExtractTarToPathWithOptions(ctx, r, dest, opts)
+}
+
+// archiverOrDefault returns a, or TarArchiver when a is nil.
+func archiverOrDefault(a Archiver) Archiver {
+	if a == nil {
+		return TarArchiver{}
+	}
+	return a
+}
+
+// isTarArchiver reports whether a produces plain tar streams, which the
+// concurrent upload path relies on.
+func isTarArchiver(a Archiver) bool {
+	_, ok := archiverOrDefault(a).(TarArchiver)
+	return ok
+}
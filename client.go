@@ -1,8 +1,8 @@
 package slicer
 
 import (
-	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,30 @@ import (
 var (
 	// ErrSecretExists is an error returned when a secret with given name already exists.
 	ErrSecretExists = errors.New("secret already exists")
+
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrNotSupported is returned when the server or guest agent does not
+	// support the requested operation.
+	ErrNotSupported = errors.New("not supported")
+
+	// ErrConflict is returned when a conditional update fails because the
+	// resource was modified since its ETag was read.
+	ErrConflict = errors.New("conflict")
+
+	// ErrUnauthorized is returned when the API rejects the token (HTTP 401),
+	// for instance because it is missing or has expired.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrForbidden is returned when the token is valid but is not permitted
+	// to perform the operation (HTTP 403).
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrEmptyResponse is returned when a successful response that should
+	// carry a JSON body has none, e.g. a misbehaving proxy answering 200
+	// with no content.
+	ErrEmptyResponse = errors.New("empty response body")
 )
 
 // SlicerClient handles all HTTP communication with the Slicer API
@@ -31,6 +56,32 @@ type SlicerClient struct {
 	token      string
 	userAgent  string
 	unixSocket string // Path to Unix socket if using Unix socket transport
+
+	ownsTransport bool // True when the client created its own transport
+	userTransport bool // True when the caller supplied the http.Client or RoundTripper
+
+	transportOpts transportOptions // Set by the transport tuning options
+
+	capabilities capabilitiesCache
+
+	dryRun func(RequestInfo) // Set by WithDryRun
+
+	maxLogLines int // Largest lines value GetVMLogs accepts; 0 for no limit
+
+	maxErrorBody int // Set by WithMaxErrorBodySize; 0 for the default
+
+	execUnmarshal  func(data []byte, v any) error // Set by WithExecUnmarshal
+	execBufferSize int                            // Set by WithExecBufferSize
+
+	copyTimeout time.Duration // Set by WithDefaultCopyTimeout
+
+	responseHook func(ResponseInfo) // Set by WithResponseHook
+	rawResponses bool               // Set by WithDisableAutoDecompress
+
+	responseCache *responseCache // Set by WithResponseCache
+
+	retryAttempts int           // Set by WithRetry
+	retryBackoff  time.Duration // Set by WithRetry
 }
 
 // isUnixSocketPath checks if the given path is a Unix socket path
@@ -70,7 +121,8 @@ func normalizeUnixSocketPath(path string) (string, bool) {
 // NewSlicerClient creates a new Slicer API client
 // If baseURL is a Unix socket path (starts with "/" or "./"), it will create
 // a custom HTTP client that uses Unix socket transport.
-func NewSlicerClient(baseURL, token string, userAgent string, httpClient *http.Client) *SlicerClient {
+// Optional ClientOptions are applied after the client is constructed.
+func NewSlicerClient(baseURL, token string, userAgent string, httpClient *http.Client, opts ...ClientOption) *SlicerClient {
 	var unixSocket string
 	var client *http.Client
 
@@ -99,13 +151,71 @@ func NewSlicerClient(baseURL, token string, userAgent string, httpClient *http.C
 		}
 	}
 
-	return &SlicerClient{
+	c := &SlicerClient{
 		httpClient: client,
 		baseURL:    baseURL,
 		token:      token,
 		userAgent:  userAgent,
 		unixSocket: unixSocket,
+		// Only the Unix socket transport is created by the client; the
+		// default and user-supplied clients may be shared.
+		ownsTransport: unixSocket != "",
+		userTransport: unixSocket == "" && httpClient != nil,
+		maxLogLines:   DefaultMaxLogLines,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.applyTransportOptions()
+
+	if c.retryAttempts > 1 {
+		next := c.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		backoff := c.retryBackoff
+		if backoff <= 0 {
+			backoff = 200 * time.Millisecond
+		}
+		hc := *c.httpClient
+		hc.Transport = &retryTransport{next: next, attempts: c.retryAttempts, backoff: backoff}
+		c.httpClient = &hc
+	}
+
+	if c.responseHook != nil || c.rawResponses {
+		next := c.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc := *c.httpClient
+		hc.Transport = &responseTransport{next: next, hook: c.responseHook, raw: c.rawResponses}
+		c.httpClient = &hc
+	}
+
+	if c.dryRun != nil {
+		next := c.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc := *c.httpClient
+		hc.Transport = &dryRunTransport{next: next, fn: c.dryRun}
+		c.httpClient = &hc
 	}
+
+	return c
+}
+
+// Close releases idle keep-alive connections held by the client's own
+// transport. It is a no-op when the client uses http.DefaultClient or a
+// user-supplied http.Client or RoundTripper, since those may be shared.
+// The client must not be used after Close.
+func (c *SlicerClient) Close() error {
+	if c.ownsTransport {
+		c.httpClient.CloseIdleConnections()
+	}
+	return nil
 }
 
 // NewClientFromEnv creates a client using environment credentials.
@@ -176,6 +286,17 @@ func (c *SlicerClient) makeJSONRequest(method, endpoint string, body interface{}
 
 // makeJSONRequest creates and executes an HTTP request with proper authentication
 func (c *SlicerClient) makeJSONRequestWithContext(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
+	req, err := c.newJSONRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+// newJSONRequest creates an HTTP request with proper authentication, for
+// callers that need to set extra headers before executing it.
+func (c *SlicerClient) newJSONRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Request, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
@@ -197,9 +318,10 @@ func (c *SlicerClient) makeJSONRequestWithContext(ctx context.Context, method, e
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 
+	req.Header.Set("Accept", "application/json")
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -207,7 +329,7 @@ func (c *SlicerClient) makeJSONRequestWithContext(ctx context.Context, method, e
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	return c.httpClient.Do(req)
+	return req, nil
 }
 
 // resolveDefaultHostGroup returns the name of the only configured host group.
@@ -235,30 +357,8 @@ func (c *SlicerClient) resolveDefaultHostGroup(ctx context.Context) (string, err
 
 // GetHostGroups fetches all host groups from the API
 func (c *SlicerClient) GetHostGroups(ctx context.Context) ([]SlicerHostGroup, error) {
-	res, err := c.makeJSONRequestWithContext(ctx, http.MethodGet, "/hostgroup", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	var body []byte
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
-		body, _ = io.ReadAll(res.Body)
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
-	}
-
-	var hostGroups []SlicerHostGroup
-	if err := json.Unmarshal(body, &hostGroups); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return hostGroups, nil
+	hostGroups, _, err := getInto[[]SlicerHostGroup](ctx, c, "/hostgroup", nil)
+	return hostGroups, err
 }
 
 // ListOptions filters applied to node listing endpoints. Both `Tag` (exact
@@ -269,9 +369,26 @@ type ListOptions struct {
 	Tag string
 	// TagPrefix matches nodes whose tags start with this value.
 	TagPrefix string
+	// Status matches nodes in this state, e.g. NodeStatusRunning.
+	Status string
+	// Secret matches nodes that mount the secret with this name.
+	Secret string
+	// MaxStatAge is honoured by GetVMStats and StreamVMStats only, and
+	// ignored by node and secret listings: when positive, each returned
+	// SlicerNodeStat has Stale set if its snapshot is older than this or
+	// missing.
+	MaxStatAge time.Duration
 }
 
 func (o ListOptions) query() string {
+	q := o.values()
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+func (o ListOptions) values() url.Values {
 	q := url.Values{}
 	if o.Tag != "" {
 		q.Set("tag", o.Tag)
@@ -279,10 +396,26 @@ func (o ListOptions) query() string {
 	if o.TagPrefix != "" {
 		q.Set("tag_prefix", o.TagPrefix)
 	}
-	if len(q) == 0 {
-		return ""
+	if o.Status != "" {
+		q.Set("status", o.Status)
 	}
-	return "?" + q.Encode()
+	if o.Secret != "" {
+		q.Set("secret", o.Secret)
+	}
+	return q
+}
+
+// matchesTags reports whether tags satisfy the Tag and TagPrefix filters.
+func (o ListOptions) matchesTags(tags []string) bool {
+	if o.Tag != "" && !slices.Contains(tags, o.Tag) {
+		return false
+	}
+	if o.TagPrefix != "" && !slices.ContainsFunc(tags, func(tag string) bool {
+		return strings.HasPrefix(tag, o.TagPrefix)
+	}) {
+		return false
+	}
+	return true
 }
 
 // firstListOption returns the first ListOptions in the variadic slice, or
@@ -314,12 +447,12 @@ func (c *SlicerClient) GetHostGroupNodes(ctx context.Context, groupName string,
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	var nodes []SlicerNode
-	if err := json.Unmarshal(body, &nodes); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &nodes); err != nil {
+		return nil, err
 	}
 
 	return nodes, nil
@@ -338,10 +471,60 @@ func (c *SlicerClient) CreateVM(ctx context.Context, groupName string, request S
 // error without touching the server further. Callers that already know the
 // group name should always pass it in to avoid the extra list round-trip.
 func (c *SlicerClient) CreateVMWithOptions(ctx context.Context, groupName string, request SlicerCreateNodeRequest, options SlicerCreateNodeOptions) (*SlicerCreateNodeResponse, error) {
+	req, groupName, err := c.prepareCreateRequest(ctx, groupName, request, options)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create node: %w", err)
+	}
+
+	var body []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		body, _ = io.ReadAll(res.Body)
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return nil, createNodeError(res, body, groupName, request)
+	}
+
+	var result SlicerCreateNodeResponse
+	if err := decodeJSONBody(res, body, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// prepareCreateRequest validates a create request, resolves an empty
+// groupName and builds the POST shared by CreateVMWithOptions and
+// CreateVMStreamWithOptions. It returns the resolved group name.
+func (c *SlicerClient) prepareCreateRequest(ctx context.Context, groupName string, request SlicerCreateNodeRequest, options SlicerCreateNodeOptions) (*http.Request, string, error) {
+	if options.ValidateUserdata {
+		if err := ValidateUserdata(request.Userdata); err != nil {
+			return nil, "", err
+		}
+	}
+	if request.DiskSizeGB < 0 {
+		return nil, "", fmt.Errorf("invalid disk size: %d GB", request.DiskSizeGB)
+	}
+	if err := validateCreateIP(request.IP); err != nil {
+		return nil, "", err
+	}
+	if err := c.checkCreateTTL(request.TTL); err != nil {
+		return nil, "", err
+	}
+
 	if strings.TrimSpace(groupName) == "" {
 		resolved, err := c.resolveDefaultHostGroup(ctx)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		groupName = resolved
 	}
@@ -349,7 +532,7 @@ func (c *SlicerClient) CreateVMWithOptions(ctx context.Context, groupName string
 	endpoint := fmt.Sprintf("hostgroup/%s/nodes", groupName)
 	reqURL, err := url.Parse(c.baseURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid base URL: %w", err)
+		return nil, "", fmt.Errorf("invalid base URL: %w", err)
 	}
 	reqURL.Path = path.Join(reqURL.Path, endpoint)
 
@@ -359,7 +542,7 @@ func (c *SlicerClient) CreateVMWithOptions(ctx context.Context, groupName string
 		case SlicerCreateNodeWaitAgent, SlicerCreateNodeWaitUserdata:
 			query.Set("wait", string(options.Wait))
 		default:
-			return nil, fmt.Errorf("invalid wait value: %q", options.Wait)
+			return nil, "", fmt.Errorf("invalid wait value: %q", options.Wait)
 		}
 	}
 	if options.Timeout > 0 {
@@ -369,46 +552,45 @@ func (c *SlicerClient) CreateVMWithOptions(ctx context.Context, groupName string
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), bytes.NewReader(requestBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create node: %w", err)
+		return nil, "", fmt.Errorf("failed to create node: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
-	}
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create node: %w", err)
-	}
+	req.Header.Set("Accept", "application/json")
+	c.setAuthHeaders(req)
 
-	var body []byte
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
-		body, _ = io.ReadAll(res.Body)
-	}
-
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
-	}
+	return req, groupName, nil
+}
 
-	var result SlicerCreateNodeResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// createNodeError explains a failed create, naming the request field that
+// was rejected when the server's message points at it.
+func createNodeError(res *http.Response, body []byte, groupName string, request SlicerCreateNodeRequest) error {
+	apiErr := newAPIError(res, body)
+
+	if request.DiskSizeGB > 0 && !request.Persistent &&
+		(res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnprocessableEntity) &&
+		strings.Contains(strings.ToLower(apiErr.Body), "disk") {
+		return fmt.Errorf("API request failed: DiskSizeGB was rejected for a non-persistent VM, set Persistent or leave DiskSizeGB unset: %w", apiErr)
+	}
+	if request.HostNode != "" {
+		switch res.StatusCode {
+		case http.StatusNotFound:
+			// A missing host group is a 404 too, so only name the host
+			// node when the server does.
+			if strings.Contains(apiErr.Body, request.HostNode) {
+				return fmt.Errorf("API request failed: host node %q is not known in host group %q: %w: %w", request.HostNode, groupName, apiErr, ErrNotFound)
+			}
+			return fmt.Errorf("API request failed: host group %q or host node %q was not found: %w: %w", groupName, request.HostNode, apiErr, ErrNotFound)
+		case http.StatusConflict, http.StatusUnprocessableEntity, http.StatusServiceUnavailable, http.StatusInsufficientStorage:
+			return fmt.Errorf("API request failed: host node %q cannot place the VM, it may be full: %w", request.HostNode, apiErr)
+		}
 	}
-
-	return &result, nil
+	return fmt.Errorf("API request failed: %w", apiErr)
 }
 
 // RelaunchVM relaunches a known stopped persistent VM.
@@ -429,47 +611,68 @@ func (c *SlicerClient) RelaunchVM(ctx context.Context, hostname string) (*Slicer
 	}
 
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	var result SlicerCreateNodeResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
 }
 
-// DeleteNode deletes a node from the specified host group
+// DeleteNode deletes a node from the specified host group.
+//
+// Deprecated: DeleteNode cannot be cancelled. Use DeleteVM, which takes a
+// context like every other method.
 func (c *SlicerClient) DeleteNode(groupName, nodeName string) error {
-	endpoint := fmt.Sprintf("hostgroup/%s/nodes/%s", groupName, nodeName)
-	res, err := c.makeJSONRequest(http.MethodDelete, endpoint, nil)
+	_, err := c.DeleteVM(context.Background(), groupName, nodeName)
+	return err
+}
+
+// ListSecrets retrieves all secrets.
+// Note: The actual secret data is not returned for security reasons.
+func (c *SlicerClient) ListSecrets(ctx context.Context) ([]Secret, error) {
+	secrets, _, err := c.listSecrets(ctx, PageOptions{})
+	return secrets, err
+}
+
+// listSecrets fetches one page of secrets and the cursor for the next page.
+func (c *SlicerClient) listSecrets(ctx context.Context, page PageOptions) ([]Secret, string, error) {
+	q := url.Values{}
+	page.set(q)
+
+	secrets, header, err := getInto[[]Secret](ctx, c, "/secrets", q)
 	if err != nil {
-		return fmt.Errorf("failed to delete node: %w", err)
+		return nil, "", err
 	}
+	return secrets, header.Get(nextCursorHeader), nil
+}
 
-	var body []byte
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
-		body, _ = io.ReadAll(res.Body)
+// CreateSecret creates a new secret.
+// Returns ErrSecretExists if a secret with the same name already exists.
+// An error is returned if creation fails.
+func (c *SlicerClient) CreateSecret(ctx context.Context, request CreateSecretRequest) error {
+	perm, err := normalizePermissions(request.Permissions)
+	if err != nil {
+		return err
 	}
+	request.Permissions = perm
 
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+	req, err := c.newJSONRequest(ctx, http.MethodPost, "/secrets", request)
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+	if request.Compress {
+		if err := c.compressSecretRequest(ctx, req); err != nil {
+			return fmt.Errorf("failed to create secret: %w", err)
+		}
 	}
 
-	return nil
-}
-
-// ListSecrets retrieves all secrets.
-// Note: The actual secret data is not returned for security reasons.
-func (c *SlicerClient) ListSecrets(ctx context.Context) ([]Secret, error) {
-	res, err := c.makeJSONRequestWithContext(ctx, http.MethodGet, "/secrets", nil)
+	res, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list secrets: %w", err)
+		return fmt.Errorf("failed to create secret: %w", err)
 	}
 
 	var body []byte
@@ -481,25 +684,25 @@ func (c *SlicerClient) ListSecrets(ctx context.Context) ([]Secret, error) {
 		body, _ = io.ReadAll(res.Body)
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+	if res.StatusCode == http.StatusConflict {
+		return ErrSecretExists
 	}
 
-	var secrets []Secret
-	if err := json.Unmarshal(body, &secrets); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
-	return secrets, nil
+	return nil
 }
 
-// CreateSecret creates a new secret.
-// Returns ErrSecretExists if a secret with the same name already exists.
-// An error is returned if creation fails.
-func (c *SlicerClient) CreateSecret(ctx context.Context, request CreateSecretRequest) error {
-	res, err := c.makeJSONRequestWithContext(ctx, http.MethodPost, "/secrets", request)
+// GetSecret retrieves a single secret's metadata, including its ETag.
+// Note: The actual secret data is not returned for security reasons.
+// Returns an error wrapping ErrNotFound if the secret doesn't exist.
+func (c *SlicerClient) GetSecret(ctx context.Context, secretName string) (*Secret, error) {
+	endpoint := path.Join("/secrets", secretName)
+	res, err := c.makeJSONRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create secret: %w", err)
+		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
 
 	var body []byte
@@ -511,23 +714,52 @@ func (c *SlicerClient) CreateSecret(ctx context.Context, request CreateSecretReq
 		body, _ = io.ReadAll(res.Body)
 	}
 
-	if res.StatusCode == http.StatusConflict {
-		return ErrSecretExists
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
 	}
 
-	if res.StatusCode != http.StatusCreated {
-		return fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
-	return nil
+	var secret Secret
+	if err := decodeJSONBody(res, body, &secret); err != nil {
+		return nil, err
+	}
+	if etag := res.Header.Get("ETag"); etag != "" {
+		secret.ETag = etag
+	}
+
+	return &secret, nil
 }
 
 // PatchSecret updates an existing secret with new data and/or metadata.
 // Only the fields provided in the UpdateSecretRequest will be modified.
+// If request.IfMatch is set, the update only applies when the secret's
+// current ETag matches, otherwise ErrConflict is returned.
 // Returns an error if the secret doesn't exist or if the update fails.
 func (c *SlicerClient) PatchSecret(ctx context.Context, secretName string, request UpdateSecretRequest) error {
+	perm, err := normalizePermissions(request.Permissions)
+	if err != nil {
+		return err
+	}
+	request.Permissions = perm
+
 	endpoint := path.Join("/secrets", secretName)
-	res, err := c.makeJSONRequestWithContext(ctx, http.MethodPatch, endpoint, request)
+	req, err := c.newJSONRequest(ctx, http.MethodPatch, endpoint, request)
+	if err != nil {
+		return fmt.Errorf("failed to patch secret: %w", err)
+	}
+	if request.IfMatch != "" {
+		req.Header.Set("If-Match", request.IfMatch)
+	}
+	if request.Compress {
+		if err := c.compressSecretRequest(ctx, req); err != nil {
+			return fmt.Errorf("failed to patch secret: %w", err)
+		}
+	}
+
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to patch secret: %w", err)
 	}
@@ -541,15 +773,60 @@ func (c *SlicerClient) PatchSecret(ctx context.Context, secretName string, reque
 		body, _ = io.ReadAll(res.Body)
 	}
 
+	if res.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrConflict)
+	}
+
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
 }
 
+// compressSecretRequest gzips the JSON body of req and marks it with
+// Content-Encoding: gzip, if the server reports CompressedSecrets and the
+// body shrinks. Otherwise req is sent as it is.
+func (c *SlicerClient) compressSecretRequest(ctx context.Context, req *http.Request) error {
+	caps, err := c.GetCapabilities(ctx)
+	if err != nil || caps.Inferred || !caps.CompressedSecrets {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if buf.Len() >= len(raw) {
+		return nil
+	}
+
+	compressed := buf.Bytes()
+	req.Body = io.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
 // DeleteSecret removes a secret.
-// Returns an error if the secret doesn't exist or if the deletion fails.
+// Returns an error wrapping ErrNotFound if the secret doesn't exist, or an
+// error if the deletion fails.
 func (c *SlicerClient) DeleteSecret(ctx context.Context, secretName string) error {
 	endpoint := path.Join("secrets", secretName)
 	res, err := c.makeJSONRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
@@ -566,35 +843,77 @@ func (c *SlicerClient) DeleteSecret(ctx context.Context, secretName string) erro
 		body, _ = io.ReadAll(res.Body)
 	}
 
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	}
+
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
 }
 
-// Exec executes a command on the specified node and streams the output.
-// The channel is unbuffered so the caller should read from it promptly to avoid blocking.
-func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq SlicerExecRequest) (chan SlicerExecWriteResult, error) {
+// DeleteSecretIfExists deletes a secret, treating a secret that does not
+// exist as already deleted.
+func (c *SlicerClient) DeleteSecretIfExists(ctx context.Context, secretName string) error {
+	if err := c.DeleteSecret(ctx, secretName); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
 
-	resChan := make(chan SlicerExecWriteResult)
+// RenameSecret renames a secret on the server in a single step, so VMs and
+// tooling never see it missing or duplicated. Since secret data cannot be
+// read back, there is no safe client-side fallback via create and delete.
+//
+// Returns an error wrapping ErrNotFound if oldName does not exist,
+// ErrSecretExists if newName is taken, or ErrNotSupported if the server has
+// no rename endpoint.
+func (c *SlicerClient) RenameSecret(ctx context.Context, oldName, newName string) error {
+	endpoint := path.Join("/secrets", oldName, "rename")
+	res, err := c.makeJSONRequestWithContext(ctx, http.MethodPost, endpoint, struct {
+		Name string `json:"name"`
+	}{Name: newName})
+	if err != nil {
+		return fmt.Errorf("failed to rename secret: %w", err)
+	}
 
-	command := execReq.Command
-	args := execReq.Args
-	uid := execReq.UID
-	gid := execReq.GID
-	shell := execReq.Shell
-	stdin := execReq.Stdin
+	var body []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		body, _ = io.ReadAll(res.Body)
+	}
 
-	cwd := execReq.Cwd
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	case http.StatusConflict:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrSecretExists)
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotSupported)
+	}
+	return fmt.Errorf("API request failed: %w", newAPIError(res, body))
+}
 
+// execQuery encodes the command, user and environment of an exec request as
+// query parameters. Stdin handling is left to the caller.
+func execQuery(execReq SlicerExecRequest) (url.Values, error) {
 	q := url.Values{}
-	q.Set("cmd", command)
+	q.Set("cmd", execReq.Command)
 	if err := setExecStdioQuery(q, execReq); err != nil {
-		return resChan, err
+		return nil, err
+	}
+	if err := setExecExtraQuery(q, execReq); err != nil {
+		return nil, err
 	}
 
-	for _, arg := range args {
+	for _, arg := range execReq.Args {
 		q.Add("args", arg)
 	}
 
@@ -602,30 +921,60 @@ func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq Slicer
 		q.Add("env", env)
 	}
 
-	if uid != NonRootUser {
-		q.Set("uid", strconv.FormatUint(uint64(uid), 10))
+	if execReq.UID != NonRootUser {
+		q.Set("uid", strconv.FormatUint(uint64(execReq.UID), 10))
 	}
-	if gid != NonRootUser {
-		q.Set("gid", strconv.FormatUint(uint64(gid), 10))
+	if execReq.GID != NonRootUser {
+		q.Set("gid", strconv.FormatUint(uint64(execReq.GID), 10))
 	}
 
-	if len(cwd) > 0 {
-		q.Set("cwd", cwd)
+	if len(execReq.Cwd) > 0 {
+		q.Set("cwd", execReq.Cwd)
 	}
 
 	if len(execReq.Permissions) > 0 {
 		q.Set("permissions", execReq.Permissions)
 	}
 
+	setExecShellQuery(q, execReq)
+
+	return q, nil
+}
+
+// Exec executes a command on the specified node and streams the output.
+// The channel is unbuffered unless the client was created with
+// WithExecBufferSize, so the caller should read from it promptly: while
+// the channel is full the SDK stops reading the response, and the command
+// is eventually paused by TCP flow control until frames are received.
+//
+// If ctx is cancelled while the command is running, a final frame is sent
+// before the channel closes, carrying any output that was already read and
+// Error set to ctx.Err().Error(), e.g. "context canceled", so callers can
+// still show partial output. Keep reading until the channel is closed. A
+// caller that stops reading instead is not waited on for long: the frame is
+// dropped after a short grace period and the response is released.
+func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq SlicerExecRequest) (chan SlicerExecWriteResult, error) {
+	ctx, cancel := c.copyContext(ctx)
+	streaming := false
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
+
+	resChan := make(chan SlicerExecWriteResult, c.execBufferSize)
+
+	q, err := execQuery(execReq)
+	if err != nil {
+		return resChan, err
+	}
+
 	var bodyReader io.Reader
 
-	if stdin {
+	if execReq.Stdin {
 		q.Set("stdin", "true")
 		bodyReader = os.Stdin
 	}
-	if len(shell) > 0 {
-		q.Set("shell", shell)
-	}
 
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
@@ -642,7 +991,7 @@ func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq Slicer
 
 	req.URL.RawQuery = q.Encode()
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return resChan, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -656,77 +1005,70 @@ func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq Slicer
 			}()
 			body, _ = io.ReadAll(res.Body)
 		}
-		return resChan, fmt.Errorf("failed to execute command: %s %s", res.Status, string(body))
+		return resChan, fmt.Errorf("failed to execute command: %w", newAPIError(res, body))
 	}
 
 	if res.Body == nil {
 		return resChan, fmt.Errorf("no body received from VM")
 	}
 
+	streaming = true
 	go func() {
-		r := bufio.NewReader(res.Body)
+		frames := c.newExecFrameReader(res.Body, execReq)
 
+		defer cancel()
 		defer res.Body.Close()
 		defer close(resChan)
 
 		for {
 			select {
 			case <-ctx.Done():
+				sendFinal(resChan, frames.canceled(ctx.Err()))
 				return
 			default:
 			}
 
-			line, err := r.ReadBytes('\n')
+			result, err := frames.Next()
 			if err == io.EOF {
-				// AE: Potential missing data if line contains some text, but we still hit EOF
 				break
 			}
-
-			if err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: time.Now(),
-					Error:     fmt.Sprintf("failed to read response: %v", err),
-				}
+			if err != nil && ctx.Err() != nil {
+				sendFinal(resChan, frames.canceled(ctx.Err()))
 				return
 			}
-
-			var result SlicerExecWriteResult
-			if err := json.Unmarshal(line, &result); err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: result.Timestamp,
-					Error:     fmt.Sprintf("failed to decode response: %v", err),
-				}
-				return
-			}
-			if err := decodeExecWriteResult(&result); err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: result.Timestamp,
+			if err != nil {
+				frames.send(ctx, resChan, SlicerExecWriteResult{
+					Timestamp: time.Now(),
 					Error:     err.Error(),
-				}
+					readErr:   err,
+				})
 				return
 			}
 
 			if result.Error != "" {
-				resChan <- SlicerExecWriteResult{
+				frames.send(ctx, resChan, SlicerExecWriteResult{
 					Timestamp: result.Timestamp,
 					Error:     fmt.Sprintf("failed to execute command: %s", result.Error),
 					Stdout:    result.Stdout,
 					Stderr:    result.Stderr,
-				}
+				})
 				return
 			}
 
 			if result.ExitCode != 0 {
-				resChan <- SlicerExecWriteResult{
+				frames.send(ctx, resChan, SlicerExecWriteResult{
 					Timestamp: result.Timestamp,
 					Error:     fmt.Sprintf("failed to execute command: %d", result.ExitCode),
 					Stdout:    result.Stdout,
 					Stderr:    result.Stderr,
-				}
+					ExitCode:  result.ExitCode,
+				})
 				return
 			}
 
-			resChan <- result
+			if !frames.send(ctx, resChan, result) {
+				return
+			}
 		}
 
 	}()
@@ -737,53 +1079,22 @@ func (c *SlicerClient) Exec(ctx context.Context, nodeName string, execReq Slicer
 // ExecBuffered executes a command and returns a single buffered result.
 // Unlike Exec, this method waits for process completion and returns a single
 // structured result suitable for non-streaming callers.
+// A command that runs but fails is reported via ExecResult.Err, not the
+// returned error, which is reserved for transport and API failures.
 func (c *SlicerClient) ExecBuffered(ctx context.Context, nodeName string, execReq SlicerExecRequest) (ExecResult, error) {
+	ctx, cancel := c.copyContext(ctx)
+	defer cancel()
+
 	var result ExecResult
 
 	if execReq.Stdin {
 		return result, fmt.Errorf("stdin is not supported by ExecBuffered; use ExecWithReader instead")
 	}
 
-	command := execReq.Command
-	args := execReq.Args
-	uid := execReq.UID
-	gid := execReq.GID
-	shell := execReq.Shell
-	cwd := execReq.Cwd
-
-	q := url.Values{}
-	q.Set("cmd", command)
-	if err := setExecStdioQuery(q, execReq); err != nil {
+	q, err := execQuery(execReq)
+	if err != nil {
 		return result, err
 	}
-
-	for _, arg := range args {
-		q.Add("args", arg)
-	}
-
-	for _, env := range execReq.Env {
-		q.Add("env", env)
-	}
-
-	if uid != NonRootUser {
-		q.Set("uid", strconv.FormatUint(uint64(uid), 10))
-	}
-	if gid != NonRootUser {
-		q.Set("gid", strconv.FormatUint(uint64(gid), 10))
-	}
-
-	if len(cwd) > 0 {
-		q.Set("cwd", cwd)
-	}
-
-	if len(execReq.Permissions) > 0 {
-		q.Set("permissions", execReq.Permissions)
-	}
-
-	if len(shell) > 0 {
-		q.Set("shell", shell)
-	}
-
 	q.Set("buffered", "true")
 
 	u, err := url.Parse(c.baseURL)
@@ -800,7 +1111,8 @@ func (c *SlicerClient) ExecBuffered(ctx context.Context, nodeName string, execRe
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.URL.RawQuery = q.Encode()
 
-	res, err := c.httpClient.Do(req)
+	start := time.Now()
+	res, err := c.do(req)
 	if err != nil {
 		return result, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -814,7 +1126,7 @@ func (c *SlicerClient) ExecBuffered(ctx context.Context, nodeName string, execRe
 			}()
 			body, _ = io.ReadAll(res.Body)
 		}
-		return result, fmt.Errorf("failed to execute command: %s %s", res.Status, string(body))
+		return result, fmt.Errorf("failed to execute command: %w", newAPIError(res, body))
 	}
 
 	if res.Body == nil {
@@ -825,12 +1137,31 @@ func (c *SlicerClient) ExecBuffered(ctx context.Context, nodeName string, execRe
 		_ = res.Body.Close()
 	}()
 
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+	if err := decodeJSONResponse(res, &result); err != nil {
 		return result, fmt.Errorf("failed to decode buffered exec response: %w", err)
 	}
 	if err := decodeExecResult(&result); err != nil {
 		return result, err
 	}
+	if execReq.MergeStderr {
+		result.Stdout += result.Stderr
+		result.Stderr = ""
+	}
+
+	result.Duration = time.Since(start)
+	if !result.StartedAt.IsZero() && !result.EndedAt.IsZero() {
+		result.Duration = result.EndedAt.Sub(result.StartedAt)
+	}
+
+	switch {
+	case result.Error != "":
+		result.Err = fmt.Errorf("command failed: %s", result.Error)
+	case result.ExitCode != 0:
+		result.Err = &ExitError{
+			RemoteProcessState: &RemoteProcessState{exitCode: result.ExitCode, exited: true, pid: result.Pid},
+			Stderr:             []byte(result.Stderr),
+		}
+	}
 
 	return result, nil
 }
@@ -840,6 +1171,25 @@ func (c *SlicerClient) ExecBuffered(ctx context.Context, nodeName string, execRe
 // internally and sent to the VM.
 // uid and gid specify the ownership for extracted files (0 means use default).
 func (c *SlicerClient) CpToVM(ctx context.Context, vmName, localPath, vmPath string, uid, gid uint32, permissions, mode string, excludePatterns ...string) error {
+	return c.CpToVMWithOptions(ctx, vmName, localPath, vmPath, CpToVMOptions{
+		UID:             uid,
+		GID:             gid,
+		Permissions:     permissions,
+		Mode:            mode,
+		ExcludePatterns: excludePatterns,
+	})
+}
+
+// CpToVMWithOptions is like CpToVM but takes a CpToVMOptions, e.g. to keep
+// local file modes with PreserveModes.
+//
+// Binary uploads set Request.GetBody, re-reading the file from the start, so
+// a retrying RoundTripper can resend them after a transient failure. Tar
+// uploads are streamed as they are built and cannot be replayed.
+func (c *SlicerClient) CpToVMWithOptions(ctx context.Context, vmName, localPath, vmPath string, options CpToVMOptions) error {
+	ctx, cancel := c.copyContext(ctx)
+	defer cancel()
+
 	// Get absolute path to handle symlinks correctly
 	absSrc, err := filepath.Abs(localPath)
 	if err != nil {
@@ -851,15 +1201,19 @@ func (c *SlicerClient) CpToVM(ctx context.Context, vmName, localPath, vmPath str
 		return fmt.Errorf("source does not exist: %w", err)
 	}
 
-	switch mode {
+	if err := c.checkCreateParents("CpToVM", options); err != nil {
+		return err
+	}
+
+	switch options.Mode {
 	default:
-		return fmt.Errorf("invalid mode: %s", mode)
+		return fmt.Errorf("invalid mode: %s", options.Mode)
 	case "tar":
-		if err := copyToVMTar(ctx, c, absSrc, vmName, vmPath, uid, gid, permissions, excludePatterns...); err != nil {
+		if err := copyToVMTar(ctx, c, absSrc, vmName, vmPath, options); err != nil {
 			return err
 		}
 	case "binary":
-		if err := copyToVMBinary(ctx, c, absSrc, vmName, vmPath, uid, gid, permissions); err != nil {
+		if err := copyToVMBinary(ctx, c, absSrc, vmName, vmPath, options); err != nil {
 			return err
 		}
 	}
@@ -867,77 +1221,164 @@ func (c *SlicerClient) CpToVM(ctx context.Context, vmName, localPath, vmPath str
 	return nil
 }
 
+// CpReaderToVM uploads the contents of r to the file vmPath in the VM in
+// binary mode, e.g. to write generated content without a temporary file.
+//
+// size is the exact number of bytes r will yield and is sent as the
+// Content-Length, so options.Progress reports against the same total the
+// server expects; the upload fails if r yields a different amount. Pass -1
+// when the size is not known to send the body chunked, in which case
+// Progress receives a total of -1.
+//
+// options.Mode, ExcludePatterns, PreserveModes, Concurrency and Archiver
+// do not apply and are ignored. The body cannot be replayed, so retrying
+// RoundTrippers will not resend it.
+func (c *SlicerClient) CpReaderToVM(ctx context.Context, vmName string, r io.Reader, size int64, vmPath string, options CpToVMOptions) error {
+	if size < -1 {
+		return fmt.Errorf("slicer: CpReaderToVM: invalid size: %d", size)
+	}
+
+	ctx, cancel := c.copyContext(ctx)
+	defer cancel()
+
+	if err := c.checkCreateParents("CpReaderToVM", options); err != nil {
+		return err
+	}
+
+	return postBinaryToVM(ctx, c, vmName, vmPath, options, size, func() io.Reader { return r }, false)
+}
+
 // CpFromVM copies files from a VM path to a local path.
 // The tar stream is received from the VM and extracted to localPath
 // with proper renaming logic (supports renaming files/directories).
-// If uid or gid are 0, the current user's UID/GID will be used.
-// On Windows, chown operations are skipped (uid/gid are ignored).
+// Extracted entries are owned by the current user's UID/GID; use
+// CpFromVMWithOptions with UID and GID to extract as another user, or with
+// NoChown to skip ownership changes entirely.
+// On Windows, chown operations are skipped.
 func (c *SlicerClient) CpFromVM(ctx context.Context, vmName, vmPath, localPath string, permissions, mode string, excludePatterns ...string) error {
+	return c.CpFromVMWithOptions(ctx, vmName, vmPath, localPath, CpFromVMOptions{
+		Permissions:     permissions,
+		Mode:            mode,
+		ExcludePatterns: excludePatterns,
+	})
+}
+
+// CpFromVMWithOptions is like CpFromVM but takes a CpFromVMOptions, e.g. to
+// refuse to overwrite existing local files with NoOverwrite, or to set the
+// ownership of extracted files with UID and GID.
+func (c *SlicerClient) CpFromVMWithOptions(ctx context.Context, vmName, vmPath, localPath string, options CpFromVMOptions) error {
+	ctx, cancel := c.copyContext(ctx)
+	defer cancel()
 
-	switch mode {
+	switch options.Mode {
 	default:
-		return fmt.Errorf("invalid mode: %s", mode)
+		return fmt.Errorf("invalid mode: %s", options.Mode)
 	case "tar":
-		return copyFromVMTar(ctx, c, vmName, vmPath, localPath, excludePatterns...)
+		return copyFromVMTar(ctx, c, vmName, vmPath, localPath, options)
 	case "binary":
-		return copyFromVMBinary(ctx, c, vmName, vmPath, localPath, permissions)
+		return copyFromVMBinary(ctx, c, vmName, vmPath, localPath, options)
 	}
 
 }
 
-// GetVMStats fetches stats for all VMs or a specific VM if hostname is provided.
-// If hostname is empty, returns stats for all VMs.
-func (c *SlicerClient) GetVMStats(ctx context.Context, hostname string) ([]SlicerNodeStat, error) {
-	u, err := url.Parse(c.baseURL)
+// CpFromVMTarStream copies vmPath from the VM as a tar stream and writes the
+// archive to w unchanged, e.g. to archive it or upload it elsewhere, without
+// extracting it locally. Optional excludePatterns are applied by the server
+// as for CpFromVM.
+//
+// No path validation is applied, since nothing is extracted: callers that
+// later extract the archive should use ExtractTarToPathWithOptions, which
+// rejects entries that escape the destination.
+func (c *SlicerClient) CpFromVMTarStream(ctx context.Context, vmName, vmPath string, w io.Writer, excludePatterns ...string) error {
+	ctx, cancel := c.copyContext(ctx)
+	defer cancel()
+
+	res, err := getVMArchive(ctx, c, vmName, vmPath, ContentTypeTar, excludePatterns)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse API URL: %w", err)
+		return err
 	}
+	defer drainClose(res.Body)
 
-	if hostname != "" {
-		u.Path = fmt.Sprintf("/node/%s/stats", hostname)
-	} else {
-		u.Path = "/nodes/stats"
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("failed to stream tar from VM: %w", err)
 	}
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// GetVMStats fetches stats for all VMs or a specific VM if hostname is provided.
+// If hostname is empty, returns stats for all VMs.
+//
+// With an empty hostname, an optional ListOptions Tag or TagPrefix limits
+// the stats to matching VMs, with the same semantics as ListVMs; only the
+// first opts entry is honored. The filter is sent to the server, and the
+// result is also joined against ListVMs so servers that ignore it still
+// return only matching VMs, at the cost of one extra request.
+//
+// Agents under pressure can keep answering with an old snapshot. Set
+// ListOptions.MaxStatAge to have such entries flagged with Stale.
+func (c *SlicerClient) GetVMStats(ctx context.Context, hostname string, opts ...ListOptions) ([]SlicerNodeStat, error) {
+	endpoint := "/nodes/stats"
+	if hostname != "" {
+		endpoint = fmt.Sprintf("/node/%s/stats", hostname)
 	}
 
-	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+	filter := firstListOption(opts)
+	filter.Status = ""
+	filter.Secret = ""
+	if hostname != "" || (filter.Tag == "" && filter.TagPrefix == "") {
+		stats, _, err := getInto[[]SlicerNodeStat](ctx, c, endpoint, nil)
+		markStaleStats(stats, filter.MaxStatAge)
+		return stats, err
 	}
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+
+	stats, _, err := getInto[[]SlicerNodeStat](ctx, c, endpoint, filter.values())
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
+	nodes, err := c.ListVMs(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform GET request: %w", err)
+		return nil, err
 	}
-	var body []byte
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
-		body, _ = io.ReadAll(res.Body)
+	matching := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if filter.matchesTags(node.Tags) {
+			matching[node.Hostname] = true
+		}
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+	filtered := make([]SlicerNodeStat, 0, len(stats))
+	for _, stat := range stats {
+		if matching[stat.Hostname] {
+			filtered = append(filtered, stat)
+		}
 	}
+	markStaleStats(filtered, filter.MaxStatAge)
+	return filtered, nil
+}
 
-	var stats []SlicerNodeStat
-	if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&stats); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// markStaleStats sets Stale on entries whose snapshot is older than maxAge.
+// A non-positive maxAge leaves the stats untouched.
+func markStaleStats(stats []SlicerNodeStat, maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	for i := range stats {
+		stats[i].Stale = stats[i].Snapshot.IsStale(maxAge)
 	}
-
-	return stats, nil
 }
 
-// GetVMLogs fetches logs for a specific VM
+// GetVMLogs fetches logs for a specific VM. Pass -1 for lines to fetch the
+// whole log.
+//
+// The logs are buffered in memory, so a lines value above the client's limit
+// (DefaultMaxLogLines unless changed with WithMaxLogLines) is refused with an
+// error rather than sent.
 func (c *SlicerClient) GetVMLogs(ctx context.Context, hostname string, lines int) (*SlicerLogsResponse, error) {
+	if c.maxLogLines > 0 && lines > c.maxLogLines {
+		return nil, fmt.Errorf("slicer: GetVMLogs: %d lines requested exceeds the limit of %d, see WithMaxLogLines", lines, c.maxLogLines)
+	}
+
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse API URL: %w", err)
@@ -955,14 +1396,15 @@ func (c *SlicerClient) GetVMLogs(ctx context.Context, hostname string, lines int
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	req.Header.Set("Accept", "application/json")
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch logs: %w", err)
 	}
@@ -976,12 +1418,12 @@ func (c *SlicerClient) GetVMLogs(ctx context.Context, hostname string, lines int
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	var logsRes SlicerLogsResponse
-	if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&logsRes); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &logsRes); err != nil {
+		return nil, err
 	}
 
 	return &logsRes, nil
@@ -990,75 +1432,109 @@ func (c *SlicerClient) GetVMLogs(ctx context.Context, hostname string, lines int
 // ListVMs fetches all VMs (nodes). Optional filters (tag / tag_prefix) may
 // be supplied; only the first opts entry is honored.
 func (c *SlicerClient) ListVMs(ctx context.Context, opts ...ListOptions) ([]SlicerNode, error) {
-	u, err := url.Parse(c.baseURL)
+	nodes, _, err := c.listVMs(ctx, firstListOption(opts), PageOptions{})
+	return nodes, err
+}
+
+// ListVMsByState lists the VMs whose Status matches state, compared case
+// insensitively, e.g. NodeStatusStopped. The filter is sent to the server and
+// also applied to the response, so servers that ignore it still return only
+// matching VMs. VMs from servers that do not report Status never match.
+func (c *SlicerClient) ListVMsByState(ctx context.Context, state string) ([]SlicerNode, error) {
+	nodes, err := c.ListVMs(ctx, ListOptions{Status: state})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse API URL: %w", err)
+		return nil, err
 	}
 
-	u.Path = "/nodes"
-	if qs := firstListOption(opts).query(); qs != "" {
-		u.RawQuery = strings.TrimPrefix(qs, "?")
+	matched := make([]SlicerNode, 0, len(nodes))
+	for _, node := range nodes {
+		if strings.EqualFold(node.Status, state) {
+			matched = append(matched, node)
+		}
 	}
+	return matched, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+// ListVMsUsingSecret lists the VMs that mount the named secret, e.g. to
+// refuse deleting a secret that is still in use.
+//
+// When the server advertises Capabilities.SecretFilter, its filtered node
+// list is returned as is. Otherwise the answer is client-side best-effort:
+// only VMs whose SlicerNode.Secrets name the secret are returned, so a
+// server that does not report secrets per VM yields an empty list. The
+// capabilities are fetched once and cached, since a wrong answer here can
+// make an unsafe delete look safe; if that fails, the best-effort answer
+// is given.
+func (c *SlicerClient) ListVMsUsingSecret(ctx context.Context, secretName string) ([]SlicerNode, error) {
+	nodes, err := c.ListVMs(ctx, ListOptions{Secret: secretName})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
-	}
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+	if caps, err := c.GetCapabilities(ctx); err == nil && caps.SecretFilter {
+		return nodes, nil
 	}
 
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch VMs: %w", err)
-	}
-	var body []byte
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
-		body, _ = io.ReadAll(res.Body)
+	matched := make([]SlicerNode, 0, len(nodes))
+	for _, node := range nodes {
+		if slices.Contains(node.Secrets, secretName) {
+			matched = append(matched, node)
+		}
 	}
+	return matched, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
-	}
+// listVMs fetches one page of VMs and the cursor for the next page.
+func (c *SlicerClient) listVMs(ctx context.Context, opts ListOptions, page PageOptions) ([]SlicerNode, string, error) {
+	q := opts.values()
+	page.set(q)
 
-	var nodes []SlicerNode
-	if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&nodes); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	nodes, header, err := getInto[[]SlicerNode](ctx, c, "/nodes", q)
+	if err != nil {
+		return nil, "", err
 	}
-
-	return nodes, nil
+	return nodes, header.Get(nextCursorHeader), nil
 }
 
-// DeleteVM deletes a VM from a host group
+// DeleteVM deletes a VM from a host group using the server's default
+// (graceful) behaviour.
+// Returns an error wrapping ErrNotFound if the VM does not exist.
 func (c *SlicerClient) DeleteVM(ctx context.Context, groupName, hostname string) (*SlicerDeleteResponse, error) {
+	return c.DeleteVMWithOptions(ctx, groupName, hostname, SlicerDeleteVMOptions{})
+}
+
+// DeleteVMWithOptions deletes a VM from a host group. Set options.Force to
+// skip the graceful shutdown and stop the VM immediately, e.g. when it is
+// stuck. The disk is handled the same way in both cases and reported in
+// SlicerDeleteResponse.DiskRemoved.
+// Returns an error wrapping ErrNotFound if the VM does not exist.
+func (c *SlicerClient) DeleteVMWithOptions(ctx context.Context, groupName, hostname string, options SlicerDeleteVMOptions) (*SlicerDeleteResponse, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse API URL: %w", err)
 	}
 
 	u.Path = fmt.Sprintf("/hostgroup/%s/nodes/%s", groupName, hostname)
+	if options.Force {
+		q := url.Values{}
+		q.Set("force", "true")
+		u.RawQuery = q.Encode()
+	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	req.Header.Set("Accept", "application/json")
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete VM: %w", err)
 	}
@@ -1071,13 +1547,21 @@ func (c *SlicerClient) DeleteVM(ctx context.Context, groupName, hostname string)
 		body, _ = io.ReadAll(res.Body)
 	}
 
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	}
+	if res.StatusCode == http.StatusNoContent {
+		// Older servers confirm the delete without a body.
+		return &SlicerDeleteResponse{}, nil
+	}
+
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	var delResp SlicerDeleteResponse
-	if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&delResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &delResp); err != nil {
+		return nil, err
 	}
 
 	if delResp.Error != "" {
@@ -1104,12 +1588,12 @@ func (c *SlicerClient) GetInfo(ctx context.Context) (*SlicerInfo, error) {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed: %s - %s", res.Status, string(body))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	var info SlicerInfo
-	if err := json.Unmarshal(body, &info); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &info); err != nil {
+		return nil, err
 	}
 
 	return &info, nil
@@ -1135,14 +1619,15 @@ func (c *SlicerClient) GetAgentHealth(ctx context.Context, hostname string, incl
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	req.Header.Set("Accept", "application/json")
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch agent health: %w", err)
 	}
@@ -1156,7 +1641,7 @@ func (c *SlicerClient) GetAgentHealth(ctx context.Context, hostname string, incl
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	if !includeStats {
@@ -1166,13 +1651,38 @@ func (c *SlicerClient) GetAgentHealth(ctx context.Context, hostname string, incl
 	}
 
 	var healthResp SlicerAgentHealthResponse
-	if err := json.NewDecoder(bytes.NewBuffer(body)).Decode(&healthResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := decodeJSONBody(res, body, &healthResp); err != nil {
+		return nil, err
 	}
 
 	return &healthResp, nil
 }
 
+// VMExists reports whether a VM with the given hostname exists, using a
+// HEAD request against its health endpoint so no body is transferred or
+// decoded. A 404 reports false. Any other failure, including an agent that
+// is not reachable yet, is returned as an error rather than a guess.
+func (c *SlicerClient) VMExists(ctx context.Context, hostname string) (bool, error) {
+	req, err := c.newJSONRequest(ctx, http.MethodHead, fmt.Sprintf("/vm/%s/health", hostname), nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check VM: %w", err)
+	}
+	defer drainClose(res.Body)
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("API request failed: %w", newAPIError(res, nil))
+}
+
 // Shutdown shuts down or reboots a VM.
 // If request is nil, it defaults to shutdown action.
 // The request Action field can be "shutdown" (halt) or "reboot" (restart).
@@ -1200,13 +1710,13 @@ func (c *SlicerClient) Shutdown(ctx context.Context, hostname string, request *S
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to shutdown VM: %w", err)
 	}
@@ -1220,7 +1730,7 @@ func (c *SlicerClient) Shutdown(ctx context.Context, hostname string, request *S
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -1241,13 +1751,13 @@ func (c *SlicerClient) PauseVM(ctx context.Context, hostname string) error {
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to pause VM: %w", err)
 	}
@@ -1261,7 +1771,7 @@ func (c *SlicerClient) PauseVM(ctx context.Context, hostname string) error {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -1282,13 +1792,13 @@ func (c *SlicerClient) ResumeVM(ctx context.Context, hostname string) error {
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to resume VM: %w", err)
 	}
@@ -1302,7 +1812,7 @@ func (c *SlicerClient) ResumeVM(ctx context.Context, hostname string) error {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -1323,13 +1833,13 @@ func (c *SlicerClient) SuspendVM(ctx context.Context, hostname string) error {
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to suspend VM: %w", err)
 	}
@@ -1343,7 +1853,7 @@ func (c *SlicerClient) SuspendVM(ctx context.Context, hostname string) error {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -1384,13 +1894,13 @@ func (c *SlicerClient) RestoreVMWithOptions(ctx context.Context, hostname string
 	}
 
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to restore VM: %w", err)
 	}
@@ -1404,7 +1914,7 @@ func (c *SlicerClient) RestoreVMWithOptions(ctx context.Context, hostname string
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("API request failed: %w", newAPIError(res, body))
 	}
 
 	return nil
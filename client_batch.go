@@ -0,0 +1,243 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DeleteVMs deletes several VMs from a host group concurrently, with at most
+// concurrency deletes in flight at once. A concurrency of zero or less runs
+// the deletes one at a time.
+//
+// Outcomes are reported per hostname: each hostname appears in exactly one of
+// the returned maps. A VM that no longer exists (ErrNotFound) is treated as
+// successfully deleted and reported with an empty SlicerDeleteResponse.
+//
+// Cancelling ctx stops new deletes from being issued; hostnames that were not
+// attempted are reported with ctx.Err().
+func (c *SlicerClient) DeleteVMs(ctx context.Context, groupName string, hostnames []string, concurrency int) (map[string]*SlicerDeleteResponse, map[string]error) {
+	deleted := make([]*SlicerDeleteResponse, len(hostnames))
+	failed, _ := fanOut(ctx, len(hostnames), concurrency, func(i int) error {
+		res, err := c.DeleteVM(ctx, groupName, hostnames[i])
+		if errors.Is(err, ErrNotFound) {
+			res, err = &SlicerDeleteResponse{}, nil
+		}
+		deleted[i] = res
+		return err
+	})
+
+	results := make(map[string]*SlicerDeleteResponse, len(hostnames))
+	errs := make(map[string]error)
+	for i, hostname := range hostnames {
+		if failed[i] != nil {
+			errs[hostname] = failed[i]
+			continue
+		}
+		results[hostname] = deleted[i]
+	}
+
+	return results, errs
+}
+
+// CreateVMs creates count VMs from the same request concurrently, with at
+// most concurrency creates in flight at once. A concurrency of zero or less
+// runs the creates one at a time. A request with a static IP is refused
+// before any VM is created, as every VM would be asked for the same address.
+//
+// The VMs that were created are always returned, in the order they were
+// requested, so callers can clean up after a partial failure. If any create
+// fails, the returned error joins one error per failed VM.
+//
+// Cancelling ctx stops new creates from being issued; the VMs that were not
+// attempted are reported in a single error wrapping ctx.Err().
+func (c *SlicerClient) CreateVMs(ctx context.Context, groupName string, request SlicerCreateNodeRequest, count, concurrency int) ([]SlicerCreateNodeResponse, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	if request.IP != "" {
+		return nil, fmt.Errorf("slicer: CreateVMs: a static IP (%s) cannot be shared by %d VMs", request.IP, count)
+	}
+	if strings.TrimSpace(groupName) == "" {
+		resolved, err := c.resolveDefaultHostGroup(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groupName = resolved
+	}
+
+	created := make([]*SlicerCreateNodeResponse, count)
+	failed, skipped := fanOut(ctx, count, concurrency, func(i int) error {
+		res, err := c.CreateVM(ctx, groupName, request)
+		if err != nil {
+			return fmt.Errorf("VM %d of %d: %w", i+1, count, err)
+		}
+		created[i] = res
+		return nil
+	})
+	if len(skipped) > 0 {
+		// Report the VMs that were not attempted once rather than per VM.
+		failed = append(failed[:count-len(skipped)], fmt.Errorf("%d of %d VMs not attempted: %w", len(skipped), count, ctx.Err()))
+	}
+
+	var results []SlicerCreateNodeResponse
+	for _, res := range created {
+		if res != nil {
+			results = append(results, *res)
+		}
+	}
+
+	return results, errors.Join(failed...)
+}
+
+// CreateSecrets creates several secrets concurrently, with at most
+// concurrency creates in flight at once. A concurrency of zero or less runs
+// the creates one at a time. Secret names should be unique within reqs.
+//
+// The returned map has an entry for every secret name: nil if it was
+// created, an error matching ErrSecretExists if a secret with that name
+// already exists, so declarative tooling can tell "already applied" apart
+// from a failure, or the error that stopped it being created.
+//
+// Cancelling ctx stops new creates from being issued; secrets that were not
+// attempted are reported with ctx.Err().
+func (c *SlicerClient) CreateSecrets(ctx context.Context, reqs []CreateSecretRequest, concurrency int) map[string]error {
+	failed, _ := fanOut(ctx, len(reqs), concurrency, func(i int) error {
+		return c.CreateSecret(ctx, reqs[i])
+	})
+
+	outcomes := make(map[string]error, len(reqs))
+	for i, req := range reqs {
+		outcomes[req.Name] = failed[i]
+	}
+
+	return outcomes
+}
+
+// GetAllAgentHealth fetches the agent health of every VM returned by
+// ListVMs, with at most concurrency probes in flight at once. A concurrency
+// of zero or less probes one VM at a time. Stats are included, as for
+// GetAgentHealth with includeStats set.
+//
+// Each VM appears in exactly one of the two maps, keyed by hostname: its
+// health, or the error that probing it returned. If the VMs cannot be
+// listed, the error is reported under the empty hostname "".
+//
+// Cancelling ctx stops new probes from being issued; VMs that were not
+// probed are reported with ctx.Err().
+func (c *SlicerClient) GetAllAgentHealth(ctx context.Context, concurrency int) (map[string]*SlicerAgentHealthResponse, map[string]error) {
+	health := make(map[string]*SlicerAgentHealthResponse)
+	errs := make(map[string]error)
+
+	nodes, err := c.ListVMs(ctx)
+	if err != nil {
+		errs[""] = err
+		return health, errs
+	}
+
+	probed := make([]*SlicerAgentHealthResponse, len(nodes))
+	failed, _ := fanOut(ctx, len(nodes), concurrency, func(i int) error {
+		res, err := c.GetAgentHealth(ctx, nodes[i].Hostname, true)
+		probed[i] = res
+		return err
+	})
+
+	for i, node := range nodes {
+		if failed[i] != nil {
+			errs[node.Hostname] = failed[i]
+			continue
+		}
+		health[node.Hostname] = probed[i]
+	}
+
+	return health, errs
+}
+
+// GetAllNodesByGroup fetches the nodes of every host group returned by
+// GetHostGroups, with at most concurrency groups fetched at once. A
+// concurrency of zero or less fetches one group at a time.
+//
+// Each group appears in exactly one of the two maps, keyed by group name:
+// its nodes, or the error that listing them returned. If the host groups
+// cannot be listed, the error is reported under the empty name "".
+//
+// Cancelling ctx stops new fetches from being issued; groups that were not
+// fetched are reported with ctx.Err().
+func (c *SlicerClient) GetAllNodesByGroup(ctx context.Context, concurrency int) (map[string][]SlicerNode, map[string]error) {
+	nodes := make(map[string][]SlicerNode)
+	errs := make(map[string]error)
+
+	groups, err := c.GetHostGroups(ctx)
+	if err != nil {
+		errs[""] = err
+		return nodes, errs
+	}
+
+	fetched := make([][]SlicerNode, len(groups))
+	failed, _ := fanOut(ctx, len(groups), concurrency, func(i int) error {
+		res, err := c.GetHostGroupNodes(ctx, groups[i].Name)
+		fetched[i] = res
+		return err
+	})
+
+	for i, group := range groups {
+		if failed[i] != nil {
+			errs[group.Name] = failed[i]
+			continue
+		}
+		nodes[group.Name] = fetched[i]
+	}
+
+	return nodes, errs
+}
+
+// fanOut calls fn once for each index in [0, n), each on its own goroutine,
+// with at most concurrency calls in flight at once. A concurrency of zero or
+// less runs the calls one at a time. The returned errs holds the error of
+// each call at its index.
+//
+// Once ctx is cancelled no further calls are started: the indices that were
+// never attempted are returned in skipped, always a suffix of [0, n), and
+// their entry in errs is ctx.Err(). fanOut waits for the calls already in
+// flight before returning.
+func fanOut(ctx context.Context, n, concurrency int, fn func(i int) error) (errs []error, skipped []int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	errs = make([]error, n)
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+
+		if ctx.Err() != nil {
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+				skipped = append(skipped, j)
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs, skipped
+}
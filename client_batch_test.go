@@ -0,0 +1,268 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDeleteVMs_AggregatesResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("Want %s method, got %s", http.MethodDelete, r.Method)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/vm-1"):
+			_, _ = io.WriteString(w, `{"message":"deleted","disk_removed":"true"}`)
+		case strings.HasSuffix(r.URL.Path, "/vm-2"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "not found")
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, "boom")
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	results, errs := client.DeleteVMs(context.Background(), "vm", []string{"vm-1", "vm-2", "vm-3"}, 2)
+
+	if got := results["vm-1"]; got == nil || got.Message != "deleted" {
+		t.Fatalf("Want vm-1 deleted, got %#v", got)
+	}
+	if _, ok := results["vm-2"]; !ok {
+		t.Fatalf("Want vm-2 (not found) reported as success, errs: %v", errs)
+	}
+	if errs["vm-3"] == nil {
+		t.Fatal("Want error for vm-3, got nil")
+	}
+	if len(results)+len(errs) != 3 {
+		t.Fatalf("Want 3 outcomes, got %d results and %d errors", len(results), len(errs))
+	}
+}
+
+func TestDeleteVMs_CancelledContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Want no requests after cancellation, got %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	results, errs := client.DeleteVMs(ctx, "vm", []string{"vm-1", "vm-2"}, 1)
+
+	if len(results) != 0 {
+		t.Fatalf("Want no results, got %d", len(results))
+	}
+	for _, h := range []string{"vm-1", "vm-2"} {
+		if errs[h] != context.Canceled {
+			t.Fatalf("Want context.Canceled for %s, got %v", h, errs[h])
+		}
+	}
+}
+
+func TestCreateVMs_ReturnsPartialSuccesses(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/hostgroup/vm/nodes" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+
+		if n == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, "out of capacity")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"hostname":"vm-%d"}`, n)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	created, err := client.CreateVMs(context.Background(), "vm", SlicerCreateNodeRequest{}, 3, 1)
+
+	if len(created) != 2 {
+		t.Fatalf("Want 2 VMs created, got %d", len(created))
+	}
+	if err == nil || !strings.Contains(err.Error(), "out of capacity") {
+		t.Fatalf("Want error reporting the failed VM, got %v", err)
+	}
+}
+
+func TestCreateVMs_RejectsStaticIP(t *testing.T) {
+	rt := &RecordingTransport{}
+	client := NewSlicerClient("http://slicer", "token", "test-agent", nil, WithRoundTripper(rt))
+
+	created, err := client.CreateVMs(context.Background(), "vm", SlicerCreateNodeRequest{IP: "192.168.137.10"}, 3, 3)
+	if err == nil || !strings.Contains(err.Error(), "static IP") {
+		t.Fatalf("Want a static IP error, got %v", err)
+	}
+	if len(created) != 0 || len(rt.Requests()) != 0 {
+		t.Fatalf("Want no requests sent, got %d", len(rt.Requests()))
+	}
+}
+
+func TestCreateSecrets_ReportsPerSecretOutcomes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateSecretRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		switch req.Name {
+		case "exists":
+			w.WriteHeader(http.StatusConflict)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, "boom")
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	outcomes := client.CreateSecrets(context.Background(), []CreateSecretRequest{
+		{Name: "new", Data: "a"},
+		{Name: "exists", Data: "b"},
+		{Name: "broken", Data: "c"},
+	}, 2)
+
+	if len(outcomes) != 3 {
+		t.Fatalf("Want 3 outcomes, got %v", outcomes)
+	}
+	if err, ok := outcomes["new"]; !ok || err != nil {
+		t.Fatalf("Want new created, got %v (present %v)", err, ok)
+	}
+	if !errors.Is(outcomes["exists"], ErrSecretExists) {
+		t.Fatalf("Want ErrSecretExists, got %v", outcomes["exists"])
+	}
+	if err := outcomes["broken"]; err == nil || errors.Is(err, ErrSecretExists) {
+		t.Fatalf("Want a failure for broken, got %v", err)
+	}
+}
+
+func TestGetAllAgentHealth_ReportsPerHostResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nodes":
+			json.NewEncoder(w).Encode([]SlicerNode{{Hostname: "vm-1"}, {Hostname: "vm-2"}})
+		case "/vm/vm-1/health":
+			json.NewEncoder(w).Encode(SlicerAgentHealthResponse{Hostname: "vm-1", AgentVersion: "0.1.0"})
+		case "/vm/vm-2/health":
+			http.Error(w, "agent unreachable", http.StatusBadGateway)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	health, errs := client.GetAllAgentHealth(context.Background(), 2)
+
+	if len(health) != 1 || health["vm-1"] == nil || health["vm-1"].AgentVersion != "0.1.0" {
+		t.Fatalf("Want health for vm-1 only, got %v", health)
+	}
+	if len(errs) != 1 || errs["vm-2"] == nil {
+		t.Fatalf("Want an error for vm-2 only, got %v", errs)
+	}
+}
+
+func TestGetAllNodesByGroup_ReportsPerGroupResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hostgroup":
+			json.NewEncoder(w).Encode([]SlicerHostGroup{{Name: "web"}, {Name: "db"}})
+		case "/hostgroup/web/nodes":
+			json.NewEncoder(w).Encode([]SlicerNode{{Hostname: "web-1"}, {Hostname: "web-2"}})
+		case "/hostgroup/db/nodes":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	nodes, errs := client.GetAllNodesByGroup(context.Background(), 2)
+
+	if len(nodes) != 1 || len(nodes["web"]) != 2 {
+		t.Fatalf("Want two nodes for web only, got %v", nodes)
+	}
+	if len(errs) != 1 || errs["db"] == nil {
+		t.Fatalf("Want an error for db only, got %v", errs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nodes, errs = client.GetAllNodesByGroup(ctx, 1)
+	if len(nodes) != 0 || !errors.Is(errs[""], context.Canceled) {
+		t.Fatalf("Want the cancelled listing reported under \"\", got %v and %v", nodes, errs)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+	)
+	errs, skipped := fanOut(context.Background(), 10, 3, func(i int) error {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		if i == 4 {
+			return fmt.Errorf("call %d failed", i)
+		}
+		return nil
+	})
+	if peak > 3 {
+		t.Fatalf("Want at most 3 calls in flight, got %d", peak)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("Want nothing skipped, got %v", skipped)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 4) {
+			t.Fatalf("Call %d: unexpected error %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs, skipped = fanOut(ctx, 5, 1, func(i int) error {
+		if i == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if fmt.Sprint(skipped) != "[2 3 4]" {
+		t.Fatalf("Want indices 2 to 4 skipped, got %v", skipped)
+	}
+	for _, i := range skipped {
+		if !errors.Is(errs[i], context.Canceled) {
+			t.Fatalf("Want context.Canceled for skipped index %d, got %v", i, errs[i])
+		}
+	}
+}
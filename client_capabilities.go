@@ -0,0 +1,124 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Capabilities lists the optional features offered by the Slicer server and
+// its guest agents, as reported by GetCapabilities.
+type Capabilities struct {
+	// Version is the server version.
+	Version string `json:"version,omitempty"`
+
+	// StreamingLogs is true when VM logs can be followed as they are written.
+	StreamingLogs bool `json:"streaming_logs,omitempty"`
+
+	// PTYExec is true when ExecInteractive is supported.
+	PTYExec bool `json:"pty_exec,omitempty"`
+
+	// WebSocketExec is true when ExecWebSocket is supported.
+	WebSocketExec bool `json:"websocket_exec,omitempty"`
+
+	// Gzip is true when the server can compress responses.
+	Gzip bool `json:"gzip,omitempty"`
+
+	// Resize is true when VMs can be resized while running.
+	Resize bool `json:"resize,omitempty"`
+
+	// TTL is true when the server deletes VMs created with
+	// SlicerCreateNodeRequest.TTL itself.
+	TTL bool `json:"ttl,omitempty"`
+
+	// BackgroundExec is true when the agent tracks background execs, see
+	// ExecBackground and GetExecStatus.
+	BackgroundExec bool `json:"background_exec,omitempty"`
+
+	// CpCreateParents is true when copies can create missing parent
+	// directories, see CpToVMOptions.CreateParents.
+	CpCreateParents bool `json:"cp_create_parents,omitempty"`
+
+	// CompressedSecrets is true when secrets can be sent gzipped, see
+	// CreateSecretRequest.Compress.
+	CompressedSecrets bool `json:"compressed_secrets,omitempty"`
+
+	// SecretFilter is true when the node list honours ListOptions.Secret,
+	// see ListVMsUsingSecret.
+	SecretFilter bool `json:"secret_filter,omitempty"`
+
+	// Inferred is true when the server has no capabilities endpoint and
+	// only Version could be determined, from /info. The feature flags are
+	// then unknown rather than unsupported, so callers should attempt the
+	// operation and handle ErrNotSupported.
+	Inferred bool `json:"-"`
+}
+
+// capabilitiesCache holds the result of the first successful
+// GetCapabilities call for a client.
+type capabilitiesCache struct {
+	mu   sync.Mutex
+	caps *Capabilities
+}
+
+// GetCapabilities reports which optional features the server supports, so
+// callers can branch on them instead of trial and error. The result is
+// fetched from /capabilities once and cached for the life of the client.
+//
+// Servers without a capabilities endpoint are detected from /info and
+// reported with Inferred set.
+func (c *SlicerClient) GetCapabilities(ctx context.Context) (Capabilities, error) {
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+
+	if c.capabilities.caps != nil {
+		return *c.capabilities.caps, nil
+	}
+
+	caps, err := c.fetchCapabilities(ctx)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	c.capabilities.caps = &caps
+	return caps, nil
+}
+
+// cachedCapabilities returns the capabilities from an earlier
+// GetCapabilities call without making a request.
+func (c *SlicerClient) cachedCapabilities() (Capabilities, bool) {
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+
+	if c.capabilities.caps == nil {
+		return Capabilities{}, false
+	}
+	return *c.capabilities.caps, true
+}
+
+func (c *SlicerClient) fetchCapabilities(ctx context.Context) (Capabilities, error) {
+	res, err := c.makeJSONRequestWithContext(ctx, http.MethodGet, "/capabilities", nil)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	defer drainClose(res.Body)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		var caps Capabilities
+		if err := decodeJSONResponse(res, &caps); err != nil {
+			return Capabilities{}, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return caps, nil
+	case http.StatusNotFound, http.StatusNotImplemented:
+		info, err := c.GetInfo(ctx)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		return Capabilities{Version: info.Version, Inferred: true}, nil
+	default:
+		body, _ := io.ReadAll(res.Body)
+		return Capabilities{}, fmt.Errorf("API request failed: %w", newAPIError(res, body))
+	}
+}
@@ -0,0 +1,65 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetCapabilities_CachesResult(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/capabilities" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+		calls.Add(1)
+		_, _ = io.WriteString(w, `{"version":"0.1.0","pty_exec":true}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	for i := 0; i < 2; i++ {
+		caps, err := client.GetCapabilities(context.Background())
+		if err != nil {
+			t.Fatalf("GetCapabilities() error = %v", err)
+		}
+		if !caps.PTYExec || caps.WebSocketExec || caps.Inferred {
+			t.Fatalf("Unexpected capabilities %+v", caps)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("Want 1 request, got %d", got)
+	}
+
+	_, err := client.ExecWebSocket(context.Background(), "vm-1", SlicerExecRequest{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported for WebSocket exec, got %v", err)
+	}
+}
+
+func TestGetCapabilities_InfersFromInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capabilities":
+			http.NotFound(w, r)
+		case "/info":
+			_, _ = io.WriteString(w, `{"version":"0.0.9"}`)
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	caps, err := client.GetCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	if !caps.Inferred || caps.Version != "0.0.9" {
+		t.Fatalf("Unexpected capabilities %+v", caps)
+	}
+}
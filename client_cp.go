@@ -2,15 +2,19 @@ package slicer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const fileModeHeader = "X-Slicer-File-Mode"
@@ -29,17 +33,99 @@ func getCurrentUIDGID() (uid, gid uint32) {
 	return uid, gid
 }
 
+// LookupUIDGID resolves a user name, or "user:group", to numeric IDs for
+// the UID and GID fields of secrets, exec requests and copies. With no group
+// the user's primary group is returned.
+//
+// Names are resolved on the local machine with os/user, not in the VM, so
+// the result is only meaningful when the guest has the same IDs, e.g. for
+// users created identically by userdata.
+func LookupUIDGID(name string) (uid, gid uint32, err error) {
+	userName, groupName, hasGroup := strings.Cut(name, ":")
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to look up user %q: %w", userName, err)
+	}
+	gidStr := u.Gid
+	if hasGroup {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to look up group %q: %w", groupName, err)
+		}
+		gidStr = g.Gid
+	}
+
+	parsedUID, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user %q has non-numeric uid %q", userName, u.Uid)
+	}
+	parsedGID, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("group of %q has non-numeric gid %q", name, gidStr)
+	}
+	return uint32(parsedUID), uint32(parsedGID), nil
+}
+
 // setAuthHeaders sets User-Agent and Authorization headers on the request.
 func (c *SlicerClient) setAuthHeaders(req *http.Request) {
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 }
 
-func copyToVMBinary(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath string, uid, gid uint32, permissions string) error {
+// contextReader wraps an io.Reader and fails reads with ctx.Err() once ctx
+// is done, so large uploads abort promptly on cancellation.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func copyToVMBinary(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath string, options CpToVMOptions) error {
+	f, err := os.Open(absSrc)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	if options.Permissions == "" && options.PreserveModes {
+		options.Permissions = strconv.FormatUint(uint64(info.Mode().Perm()), 8)
+	}
+
+	// Each body reads the file from the start through its own section
+	// reader, so GetBody can hand a fresh copy to net/http or a retrying
+	// RoundTripper that resends the upload after a transient failure.
+	return postBinaryToVM(ctx, c, vmName, vmPath, options, info.Size(), func() io.Reader {
+		return io.NewSectionReader(f, 0, info.Size())
+	}, true)
+}
+
+// postBinaryToVM uploads the body returned by newBody to vmPath in binary
+// mode. size is the exact body length, or -1 to send it chunked. When
+// replayable is set, newBody is also used for Request.GetBody.
+func postBinaryToVM(ctx context.Context, c *SlicerClient, vmName, vmPath string, options CpToVMOptions, size int64, newBody func() io.Reader, replayable bool) error {
+	uid, gid := options.UID, options.GID
+
+	permissions, err := normalizePermissions(options.Permissions)
+	if err != nil {
+		return err
+	}
+
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return fmt.Errorf("failed to parse API URL: %w", err)
@@ -67,24 +153,37 @@ func copyToVMBinary(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath
 	if len(permissions) > 0 {
 		q.Set("permissions", permissions)
 	}
+	if err := setCreateParentsQuery(q, options); err != nil {
+		return err
+	}
 
 	u.RawQuery = q.Encode()
 
-	f, err := os.Open(absSrc)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+	body := func() io.ReadCloser {
+		var r io.Reader = &contextReader{ctx: ctx, r: newBody()}
+		if options.Progress != nil {
+			r = &progressReader{r: r, total: size, fn: options.Progress}
+		}
+		return io.NopCloser(r)
 	}
-	defer f.Close()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), f)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body())
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	// The wrapped reader hides the size from net/http, so set it explicitly
+	// to send a fixed-length body instead of chunked encoding.
+	req.ContentLength = size
+	if replayable {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return body(), nil
+		}
+	}
 
-	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Type", ContentTypeBinary)
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to perform POST request: %w", err)
 	}
@@ -100,23 +199,173 @@ func copyToVMBinary(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath
 		if res.Body != nil {
 			body, _ = io.ReadAll(res.Body)
 		}
-		return fmt.Errorf("failed to copy to VM: %s: %s", res.Status, string(body))
+		return fmt.Errorf("failed to copy to VM: %w", newAPIError(res, body))
 	}
 
 	return nil
 }
 
-func copyToVMTar(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath string, uid, gid uint32, permissions string, excludePatterns ...string) error {
+// progressReader reports the running byte count of reads from r to fn.
+type progressReader struct {
+	r     io.Reader
+	n     int64
+	total int64
+	fn    func(written, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
+func copyToVMTar(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath string, options CpToVMOptions) error {
+	if options.Concurrency > 1 && isTarArchiver(options.Archiver) {
+		if info, err := os.Stat(absSrc); err == nil && info.IsDir() {
+			return copyToVMTarConcurrent(ctx, c, absSrc, vmName, vmPath, options)
+		}
+	}
+
 	parentDir := filepath.Dir(absSrc)
 	baseName := filepath.Base(absSrc)
 
+	return postTarToVM(ctx, c, vmName, vmPath, options, func(w io.Writer) error {
+		return archiverOrDefault(options.Archiver).Archive(ctx, w, parentDir, baseName, StreamTarOptions{
+			ExcludePatterns: options.ExcludePatterns,
+			PreserveModes:   options.PreserveModes,
+			FollowSymlinks:  options.FollowSymlinks,
+			SkipUnreadable:  options.SkipUnreadable,
+			OnSkip:          options.OnSkip,
+			OnSkipSpecial:   options.OnSkipSpecial,
+		})
+	})
+}
+
+// copyToVMTarConcurrent splits the files below absSrc into
+// options.Concurrency tar streams, balanced by size, and uploads them in
+// parallel. Directories are sent last in a stream of their own, so their
+// modes and mtimes are applied once every file is in place.
+func copyToVMTarConcurrent(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath string, options CpToVMOptions) error {
+	tarOpts := StreamTarOptions{
+		ExcludePatterns: options.ExcludePatterns,
+		PreserveModes:   options.PreserveModes,
+		FollowSymlinks:  options.FollowSymlinks,
+		SkipUnreadable:  options.SkipUnreadable,
+		OnSkip:          options.OnSkip,
+		OnSkipSpecial:   options.OnSkipSpecial,
+	}
+
+	var dirs, files []tarEntry
+	err := walkTarEntries(ctx, absSrc, tarOpts, func(e tarEntry) error {
+		if e.info.IsDir() {
+			dirs = append(dirs, e)
+		} else {
+			files = append(files, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk source: %w", err)
+	}
+
+	// Largest files first onto the least loaded shard.
+	sort.SliceStable(files, func(i, j int) bool { return files[i].info.Size() > files[j].info.Size() })
+	shards := make([][]tarEntry, min(options.Concurrency, max(len(files), 1)))
+	sizes := make([]int64, len(shards))
+	for _, f := range files {
+		smallest := 0
+		for i := range sizes {
+			if sizes[i] < sizes[smallest] {
+				smallest = i
+			}
+		}
+		shards[smallest] = append(shards[smallest], f)
+		sizes[smallest] += f.info.Size()
+	}
+
+	shardCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, shard []tarEntry) {
+			defer wg.Done()
+			err := postTarToVM(shardCtx, c, vmName, vmPath, options, func(w io.Writer) error {
+				return streamTarEntries(shardCtx, w, shard, tarOpts)
+			})
+			if err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i, shard)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		return nil
+	}
+	return postTarToVM(ctx, c, vmName, vmPath, options, func(w io.Writer) error {
+		return streamTarEntries(ctx, w, dirs, tarOpts)
+	})
+}
+
+// checkCreateParents refuses options.CreateParents when capabilities fetched
+// earlier with GetCapabilities say the agent cannot create parent
+// directories. Agents that do not know the flag would ignore it.
+func (c *SlicerClient) checkCreateParents(op string, options CpToVMOptions) error {
+	if !options.CreateParents {
+		return nil
+	}
+	if caps, ok := c.cachedCapabilities(); ok && !caps.Inferred && !caps.CpCreateParents {
+		return fmt.Errorf("slicer: %s: agent cannot create parent directories: %w", op, ErrNotSupported)
+	}
+	return nil
+}
+
+// setCreateParentsQuery adds the mkdir -p flag and the mode of created
+// directories for CpToVMOptions.CreateParents.
+func setCreateParentsQuery(q url.Values, options CpToVMOptions) error {
+	if !options.CreateParents {
+		return nil
+	}
+	q.Set("mkdirp", "true")
+	perms, err := normalizePermissions(options.ParentPermissions)
+	if err != nil {
+		return err
+	}
+	if perms != "" {
+		q.Set("parent_permissions", perms)
+	}
+	return nil
+}
+
+// postTarToVM uploads the tar stream written by stream to vmPath.
+func postTarToVM(ctx context.Context, c *SlicerClient, vmName, vmPath string, options CpToVMOptions, stream func(w io.Writer) error) error {
+	uid, gid, excludePatterns := options.UID, options.GID, options.ExcludePatterns
+	permissions, err := normalizePermissions(options.Permissions)
+	if err != nil {
+		return err
+	}
+
 	pr, pw := io.Pipe()
 	defer pr.Close()
 
 	go func() {
 		defer pw.Close()
-		if err := StreamTarArchive(ctx, pw, parentDir, baseName, excludePatterns...); err != nil {
-			pw.CloseWithError(fmt.Errorf("failed to stream tar: %w", err))
+		if err := stream(pw); err != nil {
+			pw.CloseWithError(fmt.Errorf("failed to stream archive: %w", err))
 		}
 	}()
 
@@ -132,6 +381,12 @@ func copyToVMTar(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath st
 	if len(permissions) > 0 {
 		q.Set("permissions", permissions)
 	}
+	if options.PreserveModes {
+		q.Set("preserve_modes", "true")
+	}
+	if err := setCreateParentsQuery(q, options); err != nil {
+		return err
+	}
 	for _, pattern := range excludePatterns {
 		pattern = strings.TrimSpace(pattern)
 		if pattern == "" {
@@ -153,10 +408,10 @@ func copyToVMTar(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath st
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set("Content-Type", archiverOrDefault(options.Archiver).ContentType())
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to perform POST request: %w", err)
 	}
@@ -173,13 +428,46 @@ func copyToVMTar(ctx context.Context, c *SlicerClient, absSrc, vmName, vmPath st
 		if res.Body != nil {
 			body, _ = io.ReadAll(res.Body)
 		}
-		return fmt.Errorf("failed to copy to VM: %s: %s", res.Status, string(body))
+		return fmt.Errorf("failed to copy to VM: %w", newAPIError(res, body))
 	}
 
 	return nil
 }
 
-func copyFromVMTar(ctx context.Context, c *SlicerClient, vmName, vmPath, localPath string, excludePatterns ...string) error {
+func copyFromVMTar(ctx context.Context, c *SlicerClient, vmName, vmPath, localPath string, options CpFromVMOptions) error {
+	archiver := archiverOrDefault(options.Archiver)
+	res, err := getVMArchive(ctx, c, vmName, vmPath, archiver.ContentType(), options.ExcludePatterns)
+	if err != nil {
+		return err
+	}
+	defer drainClose(res.Body)
+
+	destDir, err := prepareLocalTarDestination(localPath)
+	if err != nil {
+		return err
+	}
+
+	uid, gid := options.UID, options.GID
+	if uid == 0 && gid == 0 {
+		uid, gid = getCurrentUIDGID()
+	}
+
+	return archiver.Extract(ctx, res.Body, destDir, ExtractTarOptions{
+		UID:             uid,
+		GID:             gid,
+		NoChown:         options.NoChown,
+		ExcludePatterns: options.ExcludePatterns,
+		NoOverwrite:     options.NoOverwrite,
+		SkipUnchanged:   options.SkipUnchanged,
+		StrictPaths:     options.StrictPaths,
+		Staged:          options.Staged,
+		Sync:            options.Sync,
+	})
+}
+
+// getVMArchive requests vmPath from the VM as an archive of contentType. The
+// caller must close the response body.
+func getVMArchive(ctx context.Context, c *SlicerClient, vmName, vmPath, contentType string, excludePatterns []string) (*http.Response, error) {
 	q := url.Values{}
 	q.Set("path", vmPath)
 	q.Set("mode", "tar")
@@ -193,46 +481,38 @@ func copyFromVMTar(ctx context.Context, c *SlicerClient, vmName, vmPath, localPa
 
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
-		return fmt.Errorf("failed to parse API URL: %w", err)
+		return nil, fmt.Errorf("failed to parse API URL: %w", err)
 	}
 	u.Path = fmt.Sprintf("/vm/%s/cp", vmName)
 	u.RawQuery = q.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/x-tar")
+	req.Header.Set("Accept", contentType)
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to perform GET request: %w", err)
-	}
-	if res.Body != nil {
-		defer func() {
-			_, _ = io.Copy(io.Discard, res.Body)
-			_ = res.Body.Close()
-		}()
+		return nil, fmt.Errorf("failed to perform GET request: %w", err)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		var body []byte
-		if res.Body != nil {
-			body, _ = io.ReadAll(res.Body)
-		}
-		return fmt.Errorf("failed to copy from VM: %s: %s", res.Status, string(body))
+		defer drainClose(res.Body)
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("failed to copy from VM: %w", newAPIError(res, body))
 	}
 
-	destDir, err := prepareLocalTarDestination(localPath)
-	if err != nil {
-		return err
+	// Agents that predate format negotiation may omit Content-Type; one
+	// that answers with a different format did not understand Accept.
+	if got, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); got != "" && got != contentType && contentType != ContentTypeTar {
+		drainClose(res.Body)
+		return nil, fmt.Errorf("failed to copy from VM: server sent %s instead of %s: %w", got, contentType, ErrNotSupported)
 	}
 
-	uid, gid := getCurrentUIDGID()
-
-	return ExtractTarToPath(ctx, res.Body, destDir, uid, gid, excludePatterns...)
+	return res, nil
 }
 
 func prepareLocalTarDestination(localPath string) (string, error) {
@@ -255,7 +535,9 @@ func prepareLocalTarDestination(localPath string) (string, error) {
 	return localPath, nil
 }
 
-func copyFromVMBinary(ctx context.Context, c *SlicerClient, vmName, vmPath, localPath string, permissions string) error {
+func copyFromVMBinary(ctx context.Context, c *SlicerClient, vmName, vmPath, localPath string, options CpFromVMOptions) error {
+	permissions := options.Permissions
+
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return fmt.Errorf("failed to parse API URL: %w", err)
@@ -273,10 +555,10 @@ func copyFromVMBinary(ctx context.Context, c *SlicerClient, vmName, vmPath, loca
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/octet-stream")
+	req.Header.Set("Accept", ContentTypeBinary)
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -290,23 +572,34 @@ func copyFromVMBinary(ctx context.Context, c *SlicerClient, vmName, vmPath, loca
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("failed to copy from VM: %s: %s", res.Status, string(body))
+		return fmt.Errorf("failed to copy from VM: %w", newAPIError(res, body))
 	}
 
 	fileMode := os.FileMode(0600)
 	if len(permissions) > 0 {
-		fileMode, err = parseFileMode(permissions)
+		fileMode, err = ParsePermissions(permissions)
 		if err != nil {
-			return fmt.Errorf("invalid permissions format: %w", err)
+			return err
 		}
 	} else if mode := strings.TrimSpace(res.Header.Get(fileModeHeader)); mode != "" {
-		fileMode, err = parseFileMode(mode)
+		fileMode, err = ParsePermissions(mode)
 		if err != nil {
 			return fmt.Errorf("invalid mode returned by server: %w", err)
 		}
 	}
+	// Never create setuid, setgid or sticky files from a VM's say-so; only
+	// tar extraction with PreserveSpecialBits may restore them.
+	fileMode = fileMode.Perm()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if options.NoOverwrite {
+		flags |= os.O_EXCL
+	}
 
-	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	f, err := os.OpenFile(localPath, flags, fileMode)
+	if os.IsExist(err) {
+		return fmt.Errorf("refusing to overwrite existing file %s: %w", localPath, err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create local file: %w", err)
 	}
@@ -322,12 +615,3 @@ func copyFromVMBinary(ctx context.Context, c *SlicerClient, vmName, vmPath, loca
 
 	return nil
 }
-
-func parseFileMode(permissions string) (os.FileMode, error) {
-	permUint, err := strconv.ParseUint(permissions, 8, 32)
-	if err != nil {
-		return 0, err
-	}
-
-	return os.FileMode(permUint), nil
-}
@@ -1,8 +1,20 @@
 package slicer
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -54,3 +66,506 @@ func TestPrepareLocalTarDestination(t *testing.T) {
 		}
 	})
 }
+
+func TestContextReader_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &contextReader{ctx: ctx, r: strings.NewReader("hello world")}
+
+	buf := make([]byte, 5)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatalf("Read() before cancel error = %v", err)
+	}
+
+	cancel()
+	if _, err := r.Read(buf); err != context.Canceled {
+		t.Fatalf("Read() after cancel error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCpToVM_BinarySetsContentLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 5 {
+			t.Errorf("Want Content-Length 5, got %d (transfer encoding %v)", r.ContentLength, r.TransferEncoding)
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	if err := client.CpToVM(context.Background(), "vm-1", src, "/tmp/file.txt", 1000, 1000, "", "binary"); err != nil {
+		t.Fatalf("CpToVM() error = %v", err)
+	}
+}
+
+func TestCpReaderToVM_ProgressUsesSize(t *testing.T) {
+	var gotLength int64
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLength = r.ContentLength
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	for _, size := range []int64{11, -1} {
+		var written, total int64
+		options := CpToVMOptions{
+			Progress: func(w, t int64) { written, total = w, t },
+		}
+		// Hide the reader's type so net/http cannot infer the length.
+		r := io.MultiReader(strings.NewReader("hello world"))
+		if err := client.CpReaderToVM(context.Background(), "vm-1", r, size, "/etc/app.conf", options); err != nil {
+			t.Fatalf("CpReaderToVM(size=%d) error = %v", size, err)
+		}
+		if gotLength != size {
+			t.Errorf("size=%d: want Content-Length %d, got %d", size, size, gotLength)
+		}
+		if gotBody != "hello world" {
+			t.Errorf("size=%d: want body %q, got %q", size, "hello world", gotBody)
+		}
+		if written != 11 || total != size {
+			t.Errorf("size=%d: want final progress (11, %d), got (%d, %d)", size, size, written, total)
+		}
+	}
+}
+
+func TestCpToVMWithOptions_CreateParents(t *testing.T) {
+	caps := `{"version":"0.1.0","cp_create_parents":true}`
+	var (
+		query        url.Values
+		capsRequests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/capabilities" {
+			capsRequests++
+			_, _ = io.WriteString(w, caps)
+			return
+		}
+		query = r.URL.Query()
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+	opts := CpToVMOptions{Mode: "binary", CreateParents: true, ParentPermissions: "0750"}
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	if err := client.CpToVMWithOptions(context.Background(), "vm-1", src, "/opt/app/conf/file.txt", opts); err != nil {
+		t.Fatalf("CpToVMWithOptions() error = %v", err)
+	}
+	if query.Get("mkdirp") != "true" || query.Get("parent_permissions") != "750" {
+		t.Fatalf("Want mkdirp=true and parent_permissions=750, got %v", query)
+	}
+
+	if capsRequests != 0 {
+		t.Fatalf("Want no capabilities request before GetCapabilities, got %d", capsRequests)
+	}
+
+	caps = `{"version":"0.1.0"}`
+	client = NewSlicerClient(server.URL, "token", "test-agent", nil)
+	if _, err := client.GetCapabilities(context.Background()); err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	err := client.CpToVMWithOptions(context.Background(), "vm-1", src, "/opt/app/conf/file.txt", opts)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported from an agent without CpCreateParents, got %v", err)
+	}
+	err = client.CpReaderToVM(context.Background(), "vm-1", strings.NewReader("hello"), 5, "/opt/app/conf/file.txt", opts)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("CpReaderToVM(): want ErrNotSupported from an agent without CpCreateParents, got %v", err)
+	}
+}
+
+// retryOnceTransport fails the first attempt after consuming part of the
+// body, then resends the request with a body from GetBody.
+type retryOnceTransport struct {
+	bodies []string
+}
+
+func (t *retryOnceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, _ = io.ReadFull(req.Body, make([]byte, 2))
+	_ = req.Body.Close()
+
+	if req.GetBody == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	t.bodies = append(t.bodies, string(data))
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestCpToVM_BinaryBodyIsReplayable(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+
+	rt := &retryOnceTransport{}
+	client := NewSlicerClient("http://slicer", "token", "test-agent", nil, WithRoundTripper(rt))
+	if err := client.CpToVM(context.Background(), "vm-1", src, "/tmp/file.txt", 1000, 1000, "", "binary"); err != nil {
+		t.Fatalf("CpToVM() error = %v", err)
+	}
+	if len(rt.bodies) != 1 || rt.bodies[0] != "hello" {
+		t.Fatalf("Want the full file resent, got %q", rt.bodies)
+	}
+}
+
+func TestCpToVMWithOptions_PreserveModesSendsSourceMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("permissions"); got != "750" {
+			t.Errorf("Want permissions 750, got %q", got)
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(src, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+	if err := os.Chmod(src, 0o750); err != nil {
+		t.Fatalf("failed to chmod source file: %v", err)
+	}
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	err := client.CpToVMWithOptions(context.Background(), "vm-1", src, "/tmp/run.sh", CpToVMOptions{
+		Mode:          "binary",
+		PreserveModes: true,
+	})
+	if err != nil {
+		t.Fatalf("CpToVMWithOptions() error = %v", err)
+	}
+}
+
+func TestCpToVMWithOptions_ConcurrentTar(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		files    = map[string]string{}
+		requests [][]string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		tr := tar.NewReader(r.Body)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("read tar: %v", err)
+				return
+			}
+			names = append(names, header.Name)
+			if header.Typeflag == tar.TypeReg {
+				data, _ := io.ReadAll(tr)
+				mu.Lock()
+				files[header.Name] = string(data)
+				mu.Unlock()
+			}
+		}
+		mu.Lock()
+		requests = append(requests, names)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	src := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt", "sub/d.txt"} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	err := client.CpToVMWithOptions(context.Background(), "vm-1", src, "/tmp/dst", CpToVMOptions{
+		Mode:        "tar",
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatalf("CpToVMWithOptions() error = %v", err)
+	}
+
+	if len(files) != 4 || files["sub/c.txt"] != "sub/c.txt" {
+		t.Fatalf("Want all 4 files uploaded, got %v", files)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("Want 2 file streams and 1 directory stream, got %d requests", len(requests))
+	}
+	if last := requests[len(requests)-1]; len(last) != 1 || last[0] != "sub/" {
+		t.Fatalf("Want directories sent last, got %v", last)
+	}
+}
+
+func TestCpFromVM_BinaryDropsSpecialBits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(fileModeHeader, "4755")
+		_, _ = io.WriteString(w, "#!/bin/sh\n")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	dest := filepath.Join(t.TempDir(), "tool")
+	if err := client.CpFromVM(context.Background(), "vm-1", "/usr/bin/tool", dest, "", "binary"); err != nil {
+		t.Fatalf("CpFromVM() error = %v", err)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("failed to stat copied file: %v", err)
+	}
+	if info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != 0 {
+		t.Fatalf("Want special bits dropped from the server mode, got %v", info.Mode())
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("Want the executable bit kept, got %v", info.Mode())
+	}
+}
+
+func TestCpFromVMTarStream_WritesArchiveUnchanged(t *testing.T) {
+	archive := "raw tar bytes, passed through as-is"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("mode"); got != "tar" {
+			t.Errorf("Want mode=tar, got %q", got)
+		}
+		if got := r.URL.Query()["exclude"]; len(got) != 1 || got[0] != "*.log" {
+			t.Errorf("Want exclude *.log, got %v", got)
+		}
+		w.Header().Set("Content-Type", "application/x-tar")
+		_, _ = io.WriteString(w, archive)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	var buf strings.Builder
+	if err := client.CpFromVMTarStream(context.Background(), "vm-1", "/etc", &buf, "*.log"); err != nil {
+		t.Fatalf("CpFromVMTarStream() error = %v", err)
+	}
+	if buf.String() != archive {
+		t.Fatalf("Want archive written unchanged, got %q", buf.String())
+	}
+}
+
+// textArchiver is a stand-in format that archives a file's contents as-is.
+type textArchiver struct{}
+
+func (textArchiver) ContentType() string { return "text/plain" }
+
+func (textArchiver) Archive(ctx context.Context, w io.Writer, parentDir, baseName string, opts StreamTarOptions) error {
+	data, err := os.ReadFile(filepath.Join(parentDir, baseName))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (textArchiver) Extract(ctx context.Context, r io.Reader, dest string, opts ExtractTarOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, "out.txt"), data, 0o644)
+}
+
+func TestCpWithOptions_Archiver(t *testing.T) {
+	serverType := "text/plain"
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			if got := r.Header.Get("Content-Type"); got != "text/plain" {
+				t.Errorf("Want Content-Type text/plain, got %q", got)
+			}
+			data, _ := io.ReadAll(r.Body)
+			uploaded = string(data)
+		case http.MethodGet:
+			if got := r.Header.Get("Accept"); got != "text/plain" {
+				t.Errorf("Want Accept text/plain, got %q", got)
+			}
+			w.Header().Set("Content-Type", serverType)
+			_, _ = io.WriteString(w, "from vm")
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	src := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(src, []byte("to vm"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	if err := client.CpToVMWithOptions(ctx, "vm-1", src, "/tmp", CpToVMOptions{Mode: "tar", Archiver: textArchiver{}}); err != nil {
+		t.Fatalf("CpToVMWithOptions() error = %v", err)
+	}
+	if uploaded != "to vm" {
+		t.Fatalf("Want archiver output uploaded, got %q", uploaded)
+	}
+
+	dest := t.TempDir()
+	if err := client.CpFromVMWithOptions(ctx, "vm-1", "/tmp", dest, CpFromVMOptions{Mode: "tar", Archiver: textArchiver{}}); err != nil {
+		t.Fatalf("CpFromVMWithOptions() error = %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "out.txt")); string(got) != "from vm" {
+		t.Fatalf("Want archive extracted by archiver, got %q", got)
+	}
+
+	serverType = "application/x-tar"
+	err := client.CpFromVMWithOptions(ctx, "vm-1", "/tmp", dest, CpFromVMOptions{Mode: "tar", Archiver: textArchiver{}})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported when the server ignores Accept, got %v", err)
+	}
+}
+
+// ownerArchiver records the ownership Extract was asked to apply.
+type ownerArchiver struct {
+	textArchiver
+	uid, gid *uint32
+	chown    *bool
+}
+
+func (a ownerArchiver) Extract(ctx context.Context, r io.Reader, dest string, opts ExtractTarOptions) error {
+	*a.uid, *a.gid = opts.UID, opts.GID
+	if a.chown != nil {
+		*a.chown = opts.chown()
+	}
+	return a.textArchiver.Extract(ctx, r, dest, opts)
+}
+
+func TestCpFromVMWithOptions_UIDGID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "from vm")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	var uid, gid uint32
+	archiver := ownerArchiver{uid: &uid, gid: &gid}
+
+	err := client.CpFromVMWithOptions(context.Background(), "vm-1", "/tmp", t.TempDir(), CpFromVMOptions{
+		Mode:     "tar",
+		Archiver: archiver,
+		UID:      1500,
+		GID:      1600,
+	})
+	if err != nil {
+		t.Fatalf("CpFromVMWithOptions() error = %v", err)
+	}
+	if uid != 1500 || gid != 1600 {
+		t.Fatalf("Want extraction as 1500:1600, got %d:%d", uid, gid)
+	}
+
+	if err := client.CpFromVMWithOptions(context.Background(), "vm-1", "/tmp", t.TempDir(), CpFromVMOptions{Mode: "tar", Archiver: archiver}); err != nil {
+		t.Fatalf("CpFromVMWithOptions() error = %v", err)
+	}
+	wantUID, wantGID := getCurrentUIDGID()
+	if uid != wantUID || gid != wantGID {
+		t.Fatalf("Want current user %d:%d by default, got %d:%d", wantUID, wantGID, uid, gid)
+	}
+}
+
+func TestLookupUIDGID(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		t.Skipf("no primary group: %v", err)
+	}
+
+	uid, gid, err := LookupUIDGID(current.Username)
+	if err != nil {
+		t.Fatalf("LookupUIDGID() error = %v", err)
+	}
+	if strconv.FormatUint(uint64(uid), 10) != current.Uid || strconv.FormatUint(uint64(gid), 10) != current.Gid {
+		t.Fatalf("Want %s:%s, got %d:%d", current.Uid, current.Gid, uid, gid)
+	}
+
+	if _, gid, err = LookupUIDGID(current.Username + ":" + group.Name); err != nil || strconv.FormatUint(uint64(gid), 10) != group.Gid {
+		t.Fatalf("Want gid %s from group name, got %d (%v)", group.Gid, gid, err)
+	}
+
+	if _, _, err := LookupUIDGID("no-such-user-slicer-test"); err == nil {
+		t.Fatal("Want error for unknown user")
+	}
+}
+
+func TestCpFromVMWithOptions_NoChown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "from vm")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	var uid, gid uint32
+	chown := true
+	archiver := ownerArchiver{uid: &uid, gid: &gid, chown: &chown}
+
+	err := client.CpFromVMWithOptions(context.Background(), "vm-1", "/tmp", t.TempDir(), CpFromVMOptions{
+		Mode:     "tar",
+		Archiver: archiver,
+		UID:      1500,
+		GID:      1600,
+		NoChown:  true,
+	})
+	if err != nil {
+		t.Fatalf("CpFromVMWithOptions() error = %v", err)
+	}
+	if chown {
+		t.Fatal("Want no chown with NoChown, even with a UID and GID set")
+	}
+
+	// The default tar extractor must not fail on chown either, which it
+	// would as a non-root user asked for another owner.
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	_ = tw.WriteHeader(&tar.Header{Name: "f.txt", Mode: 0o644, Size: 2, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("hi"))
+	_ = tw.Close()
+	tarServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-tar")
+		_, _ = w.Write(tarBuf.Bytes())
+	}))
+	defer tarServer.Close()
+
+	dest := t.TempDir()
+	client = NewSlicerClient(tarServer.URL, "token", "test-agent", nil)
+	uid0, gid0 := getCurrentUIDGID()
+	err = client.CpFromVMWithOptions(context.Background(), "vm-1", "/tmp/f.txt", dest, CpFromVMOptions{
+		Mode:    "tar",
+		UID:     uid0 + 4242,
+		GID:     gid0 + 4242,
+		NoChown: true,
+	})
+	if err != nil {
+		t.Fatalf("CpFromVMWithOptions() error = %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dest, "f.txt")); string(got) != "hi" {
+		t.Fatalf("Want f.txt extracted, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package slicer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Provisioning phases reported in ProvisionEvent.Phase. Servers may emit
+// phases not listed here; callers should display unknown phases as-is.
+const (
+	ProvisionPhasePulling   = "pulling"
+	ProvisionPhaseBooting   = "booting"
+	ProvisionPhaseAssigning = "assigning_ip"
+	ProvisionPhaseReady     = "ready"
+	ProvisionPhaseFailed    = "failed"
+)
+
+// ProvisionEvent is one progress update from CreateVMStream. The final
+// event has Node set on success, or Error set on failure.
+type ProvisionEvent struct {
+	Timestamp time.Time                 `json:"timestamp,omitzero"`
+	Phase     string                    `json:"phase"`
+	Message   string                    `json:"message,omitempty"`
+	Node      *SlicerCreateNodeResponse `json:"node,omitempty"`
+	Error     string                    `json:"error,omitempty"`
+}
+
+// CreateVMStream creates a new VM and streams provisioning progress
+// (pulling image, booting, assigning IP) as it happens, ending with an
+// event carrying the node.
+//
+// If the server does not support streaming and answers with the plain
+// create response, a single ProvisionPhaseReady event carrying the node is
+// delivered instead. The channel is closed after the final event.
+//
+// groupName may be empty, with the same resolution rules as CreateVMWithOptions.
+func (c *SlicerClient) CreateVMStream(ctx context.Context, groupName string, request SlicerCreateNodeRequest) (<-chan ProvisionEvent, error) {
+	return c.CreateVMStreamWithOptions(ctx, groupName, request, SlicerCreateNodeOptions{})
+}
+
+// CreateVMStreamWithOptions is like CreateVMStream but takes a
+// SlicerCreateNodeOptions, e.g. to check the userdata first with
+// ValidateUserdata, as for CreateVMWithOptions.
+func (c *SlicerClient) CreateVMStreamWithOptions(ctx context.Context, groupName string, request SlicerCreateNodeRequest, options SlicerCreateNodeOptions) (<-chan ProvisionEvent, error) {
+	req, groupName, err := c.prepareCreateRequest(ctx, groupName, request, options)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Set("stream", "true")
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Accept", "application/x-ndjson, application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create node: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		defer drainClose(res.Body)
+		body, _ := io.ReadAll(res.Body)
+		return nil, createNodeError(res, body, groupName, request)
+	}
+
+	events := make(chan ProvisionEvent)
+	go func() {
+		defer drainClose(res.Body)
+		defer close(events)
+
+		send := func(evt ProvisionEvent) bool {
+			select {
+			case events <- evt:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if mediaType != "application/x-ndjson" {
+			// Server without streaming support: a single create response.
+			var node SlicerCreateNodeResponse
+			if err := decodeJSONResponse(res, &node); err != nil {
+				send(ProvisionEvent{Timestamp: time.Now(), Phase: ProvisionPhaseFailed, Error: fmt.Sprintf("failed to decode response: %v", err)})
+				return
+			}
+			send(ProvisionEvent{Timestamp: time.Now(), Phase: ProvisionPhaseReady, Node: &node})
+			return
+		}
+
+		r := bufio.NewReader(res.Body)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var evt ProvisionEvent
+				if jerr := json.Unmarshal(line, &evt); jerr != nil {
+					send(ProvisionEvent{Timestamp: time.Now(), Phase: ProvisionPhaseFailed, Error: fmt.Sprintf("failed to decode event: %v", jerr)})
+					return
+				}
+				if !send(evt) || evt.Node != nil || evt.Error != "" {
+					return
+				}
+			}
+			if err == io.EOF {
+				send(ProvisionEvent{Timestamp: time.Now(), Phase: ProvisionPhaseFailed, Error: "provisioning stream ended without a final event"})
+				return
+			}
+			if err != nil {
+				send(ProvisionEvent{Timestamp: time.Now(), Phase: ProvisionPhaseFailed, Error: fmt.Sprintf("failed to read response: %v", err)})
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
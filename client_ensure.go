@@ -0,0 +1,88 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EnsureVM returns the VM identified by key, creating it from request only
+// when no such VM exists, so reconcilers can declare a VM idempotently.
+// The returned bool reports whether a new VM was launched.
+//
+// The server assigns hostnames, so identity is keyed on the caller's side:
+//
+//   - When key is set, the VM is the one whose Tags contain key exactly.
+//     key is added to request.Tags if missing, so the created VM carries it.
+//   - When key is empty, the VM is the one with request.IP as its address.
+//
+// Only VMs in groupName are considered when it is set. More than one match
+// is reported as an error wrapping ErrConflict. The check and the create
+// are separate calls, so two callers ensuring the same key concurrently can
+// both create a VM; serialize reconcilers that share keys.
+//
+// A created VM is returned with the fields of the create response plus the
+// request's tags and secrets.
+func (c *SlicerClient) EnsureVM(ctx context.Context, groupName, key string, request SlicerCreateNodeRequest) (*SlicerNode, bool, error) {
+	var filter ListOptions
+	var match func(SlicerNode) bool
+	switch {
+	case key != "":
+		filter.Tag = key
+		match = func(n SlicerNode) bool { return slices.Contains(n.Tags, key) }
+		if !slices.Contains(request.Tags, key) {
+			request.Tags = append(slices.Clone(request.Tags), key)
+		}
+	case request.IP != "":
+		want, _ := parseNodeCIDR(request.IP)
+		if want == nil {
+			return nil, false, fmt.Errorf("slicer: EnsureVM: invalid IP %q", request.IP)
+		}
+		match = func(n SlicerNode) bool { return want.Equal(n.IPAddress()) }
+	default:
+		return nil, false, fmt.Errorf("slicer: EnsureVM: a key or request IP is required to identify the VM")
+	}
+
+	nodes, err := c.ListVMs(ctx, filter)
+	if err != nil {
+		return nil, false, fmt.Errorf("slicer: EnsureVM: %w", err)
+	}
+
+	var found []SlicerNode
+	for _, n := range nodes {
+		if groupName != "" && n.HostGroup != "" && n.HostGroup != groupName {
+			continue
+		}
+		if match(n) {
+			found = append(found, n)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+	case 1:
+		return &found[0], false, nil
+	default:
+		names := make([]string, len(found))
+		for i, n := range found {
+			names[i] = n.Hostname
+		}
+		return nil, false, fmt.Errorf("slicer: EnsureVM: %d VMs match (%s): %w", len(found), strings.Join(names, ", "), ErrConflict)
+	}
+
+	res, err := c.CreateVM(ctx, groupName, request)
+	if err != nil {
+		return nil, false, err
+	}
+	return &SlicerNode{
+		Hostname:  res.Hostname,
+		HostGroup: res.HostGroup,
+		IP:        res.IP,
+		CreatedAt: res.CreatedAt,
+		Arch:      res.Arch,
+		Gateway:   res.Gateway,
+		Tags:      request.Tags,
+		Secrets:   request.Secrets,
+	}, true, nil
+}
@@ -0,0 +1,66 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestEnsureVM(t *testing.T) {
+	var nodes []SlicerNode
+	creates := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/nodes":
+			json.NewEncoder(w).Encode(nodes)
+		case r.Method == http.MethodPost && r.URL.Path == "/hostgroup/api/nodes":
+			creates++
+			var req SlicerCreateNodeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			node := SlicerNode{Hostname: "api-1", HostGroup: "api", IP: "192.168.137.2/24", Tags: req.Tags}
+			nodes = append(nodes, node)
+			json.NewEncoder(w).Encode(SlicerCreateNodeResponse{Hostname: node.Hostname, HostGroup: node.HostGroup, IP: node.IP})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	node, created, err := client.EnsureVM(ctx, "api", "app=web", SlicerCreateNodeRequest{Tags: []string{"env=dev"}})
+	if err != nil {
+		t.Fatalf("EnsureVM() error = %v", err)
+	}
+	if !created || node.Hostname != "api-1" || !slices.Contains(node.Tags, "app=web") {
+		t.Fatalf("Want api-1 created with the key tag, got %#v, created=%v", node, created)
+	}
+
+	node, created, err = client.EnsureVM(ctx, "api", "app=web", SlicerCreateNodeRequest{})
+	if err != nil {
+		t.Fatalf("EnsureVM() error = %v", err)
+	}
+	if created || node.Hostname != "api-1" || creates != 1 {
+		t.Fatalf("Want existing api-1 returned without creating, got %#v, created=%v, creates=%d", node, created, creates)
+	}
+
+	node, created, err = client.EnsureVM(ctx, "api", "", SlicerCreateNodeRequest{IP: "192.168.137.2"})
+	if err != nil || created || node.Hostname != "api-1" {
+		t.Fatalf("Want api-1 matched by IP, got %#v, created=%v, err=%v", node, created, err)
+	}
+
+	nodes = append(nodes, SlicerNode{Hostname: "api-2", HostGroup: "api", Tags: []string{"app=web"}})
+	if _, _, err := client.EnsureVM(ctx, "api", "app=web", SlicerCreateNodeRequest{}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Want ErrConflict for duplicate matches, got %v", err)
+	}
+
+	if _, _, err := client.EnsureVM(ctx, "api", "", SlicerCreateNodeRequest{}); err == nil {
+		t.Fatal("Want error without a key or IP")
+	}
+}
@@ -0,0 +1,60 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ExpireVMAfter deletes a VM once ttl has elapsed, for servers that cannot
+// expire VMs themselves (Capabilities.TTL is false). The delete is scheduled
+// in this process: it only happens if the process is still running when ttl
+// elapses, so prefer SlicerCreateNodeRequest.TTL where the server supports
+// it:
+//
+//	caps, _ := client.GetCapabilities(ctx)
+//	if caps.TTL {
+//		req.TTL = time.Hour
+//	}
+//	node, err := client.CreateVM(ctx, group, req)
+//	...
+//	if !caps.TTL {
+//		client.ExpireVMAfter(ctx, group, node.Hostname, time.Hour)
+//	}
+//
+// Cancelling ctx before ttl elapses cancels the delete. The returned channel
+// receives the result of the delete, or ctx.Err() if it was cancelled, and
+// is then closed. Callers that do not need the result may ignore it.
+func (c *SlicerClient) ExpireVMAfter(ctx context.Context, groupName, hostname string, ttl time.Duration) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+
+		t := time.NewTimer(ttl)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			result <- ctx.Err()
+		case <-t.C:
+			_, err := c.DeleteVM(ctx, groupName, hostname)
+			result <- err
+		}
+	}()
+	return result
+}
+
+// checkCreateTTL validates SlicerCreateNodeRequest.TTL, refusing it without
+// a round trip when the server is known not to expire VMs.
+func (c *SlicerClient) checkCreateTTL(ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("invalid TTL: %s", ttl)
+	}
+	if ttl == 0 {
+		return nil
+	}
+	if caps, ok := c.cachedCapabilities(); ok && !caps.Inferred && !caps.TTL {
+		return fmt.Errorf("slicer: CreateVM: server does not support TTL, use ExpireVMAfter: %w", ErrNotSupported)
+	}
+	return nil
+}
@@ -32,7 +32,7 @@ func (c *SlicerClient) ReadFile(ctx context.Context, vmName, vmPath string) ([]b
 	req.Header.Set("Accept", "application/octet-stream")
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, "", fmt.Errorf("request failed: %w", err)
 	}
@@ -47,7 +47,7 @@ func (c *SlicerClient) ReadFile(ctx context.Context, vmName, vmPath string) ([]b
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return nil, "", fmt.Errorf("failed to read file from VM: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, "", fmt.Errorf("failed to read file from VM: %w", newAPIError(res, body))
 	}
 
 	data, err := io.ReadAll(res.Body)
@@ -65,6 +65,11 @@ func (c *SlicerClient) ReadFile(ctx context.Context, vmName, vmPath string) ([]b
 
 // WriteFile uploads a binary file to the VM.
 func (c *SlicerClient) WriteFile(ctx context.Context, vmName, vmPath string, data []byte, uid, gid uint32, permissions string) error {
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return err
+	}
+
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return fmt.Errorf("failed to parse API URL: %w", err)
@@ -97,7 +102,7 @@ func (c *SlicerClient) WriteFile(ctx context.Context, vmName, vmPath string, dat
 	req.Header.Set("Content-Type", "application/octet-stream")
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to perform POST request: %w", err)
 	}
@@ -108,7 +113,7 @@ func (c *SlicerClient) WriteFile(ctx context.Context, vmName, vmPath string, dat
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("failed to write file to VM: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("failed to write file to VM: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -132,7 +137,7 @@ func (c *SlicerClient) ReadDir(ctx context.Context, vmName, path string) ([]Slic
 	}
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -143,11 +148,11 @@ func (c *SlicerClient) ReadDir(ctx context.Context, vmName, path string) ([]Slic
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("failed to read directory: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("failed to read directory: %w", newAPIError(res, body))
 	}
 
 	var entries []SlicerFSInfo
-	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+	if err := decodeJSONResponse(res, &entries); err != nil {
 		return nil, fmt.Errorf("failed to decode directory listing: %w", err)
 	}
 
@@ -172,7 +177,7 @@ func (c *SlicerClient) Stat(ctx context.Context, vmName, path string) (*SlicerFS
 	}
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -186,11 +191,11 @@ func (c *SlicerClient) Stat(ctx context.Context, vmName, path string) (*SlicerFS
 	}
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("failed to stat path: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("failed to stat path: %w", newAPIError(res, body))
 	}
 
 	var entry SlicerFSInfo
-	if err := json.NewDecoder(res.Body).Decode(&entry); err != nil {
+	if err := decodeJSONResponse(res, &entry); err != nil {
 		return nil, fmt.Errorf("failed to decode stat result: %w", err)
 	}
 
@@ -230,7 +235,7 @@ func (c *SlicerClient) Mkdir(ctx context.Context, vmName string, request SlicerF
 	req.Header.Set("Content-Type", "application/json")
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -241,7 +246,7 @@ func (c *SlicerClient) Mkdir(ctx context.Context, vmName string, request SlicerF
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("failed to create directory: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("failed to create directory: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -266,7 +271,7 @@ func (c *SlicerClient) Remove(ctx context.Context, vmName, path string, recursiv
 	}
 	c.setAuthHeaders(req)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -277,7 +282,7 @@ func (c *SlicerClient) Remove(ctx context.Context, vmName, path string, recursiv
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("failed to remove path: %s: %s", res.Status, strings.TrimSpace(string(body)))
+		return fmt.Errorf("failed to remove path: %w", newAPIError(res, body))
 	}
 
 	return nil
@@ -0,0 +1,63 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// getInto performs a GET request to endpoint with the optional query and
+// decodes the JSON response into a T. The response headers are returned for
+// callers that need them, e.g. to read the next page cursor. With
+// WithResponseCache, a 304 answer is served from the cached response.
+func getInto[T any](ctx context.Context, c *SlicerClient, endpoint string, query url.Values) (T, http.Header, error) {
+	var out T
+
+	req, err := c.newJSONRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return out, nil, err
+	}
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if c.responseCache != nil {
+		c.responseCache.prepare(req)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return out, nil, fmt.Errorf("failed to perform GET request: %w", err)
+	}
+	defer drainClose(res.Body)
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return out, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	header := res.Header
+	switch {
+	case res.StatusCode == http.StatusNotModified && c.responseCache != nil:
+		cached, ok := c.responseCache.lookup(req)
+		if !ok {
+			return out, nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
+		}
+		body, header = cached.body, cached.header
+	case res.StatusCode != http.StatusOK:
+		return out, nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
+	}
+
+	if err := decodeJSONBody(res, body, &out); err != nil {
+		return out, nil, err
+	}
+
+	// Only a body that decoded is cached, so a truncated or malformed
+	// response is not replayed on later 304s.
+	if res.StatusCode == http.StatusOK && c.responseCache != nil {
+		c.responseCache.store(req, res, body)
+	}
+
+	return out, header, nil
+}
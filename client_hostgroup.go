@@ -0,0 +1,67 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+// CreateHostGroup creates a host group from group, whose Name is required.
+// Returns an error wrapping ErrConflict if a group with that name already
+// exists, or ErrNotSupported if the server cannot manage host groups.
+func (c *SlicerClient) CreateHostGroup(ctx context.Context, group SlicerHostGroup) error {
+	if group.Name == "" {
+		return fmt.Errorf("host group name is required")
+	}
+	return c.hostGroupRequest(ctx, http.MethodPost, "/hostgroup", group, "create")
+}
+
+// UpdateHostGroup replaces the settings of the named host group with group.
+// group.Name may be left empty; it is set to name. VMs already running in
+// the group keep their resources. Returns an error wrapping ErrNotFound if
+// the group does not exist.
+func (c *SlicerClient) UpdateHostGroup(ctx context.Context, name string, group SlicerHostGroup) error {
+	if group.Name == "" {
+		group.Name = name
+	}
+	return c.hostGroupRequest(ctx, http.MethodPut, path.Join("/hostgroup", name), group, "update")
+}
+
+// DeleteHostGroup deletes the named host group. Returns an error wrapping
+// ErrNotFound if the group does not exist, or ErrConflict if the server
+// refuses because the group still has VMs.
+func (c *SlicerClient) DeleteHostGroup(ctx context.Context, name string) error {
+	return c.hostGroupRequest(ctx, http.MethodDelete, path.Join("/hostgroup", name), nil, "delete")
+}
+
+// hostGroupRequest sends a host group mutation and maps its status codes
+// onto the package's sentinel errors.
+func (c *SlicerClient) hostGroupRequest(ctx context.Context, method, endpoint string, body interface{}, op string) error {
+	res, err := c.makeJSONRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to %s host group: %w", op, err)
+	}
+
+	var resBody []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		resBody, _ = io.ReadAll(res.Body)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, resBody), ErrNotFound)
+	case http.StatusConflict:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, resBody), ErrConflict)
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, resBody), ErrNotSupported)
+	}
+	return fmt.Errorf("API request failed: %w", newAPIError(res, resBody))
+}
@@ -0,0 +1,74 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostGroupLifecycle(t *testing.T) {
+	groups := map[string]SlicerHostGroup{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var group SlicerHostGroup
+		if r.Body != nil {
+			_ = json.NewDecoder(r.Body).Decode(&group)
+		}
+		name := r.URL.Path[len("/hostgroup"):]
+		if name != "" {
+			name = name[1:]
+		}
+
+		switch {
+		case r.Method == http.MethodPost && name == "":
+			if _, ok := groups[group.Name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			groups[group.Name] = group
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			if _, ok := groups[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			groups[name] = group
+		case r.Method == http.MethodDelete:
+			if _, ok := groups[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(groups, name)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	if err := client.CreateHostGroup(ctx, SlicerHostGroup{Name: "web", CPUs: 2}); err != nil {
+		t.Fatalf("CreateHostGroup() error = %v", err)
+	}
+	if err := client.CreateHostGroup(ctx, SlicerHostGroup{Name: "web"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Want ErrConflict for duplicate group, got %v", err)
+	}
+	if err := client.UpdateHostGroup(ctx, "web", SlicerHostGroup{CPUs: 4}); err != nil {
+		t.Fatalf("UpdateHostGroup() error = %v", err)
+	}
+	if got := groups["web"]; got.Name != "web" || got.CPUs != 4 {
+		t.Fatalf("Want web updated to 4 CPUs, got %#v", got)
+	}
+	if err := client.DeleteHostGroup(ctx, "web"); err != nil {
+		t.Fatalf("DeleteHostGroup() error = %v", err)
+	}
+	if err := client.UpdateHostGroup(ctx, "web", SlicerHostGroup{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Want ErrNotFound on update, got %v", err)
+	}
+	if err := client.DeleteHostGroup(ctx, "web"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Want ErrNotFound on delete, got %v", err)
+	}
+}
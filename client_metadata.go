@@ -0,0 +1,79 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// GetVMMetadata returns the key/value metadata of a VM, via
+// GET /hostgroup/{groupName}/nodes/{hostname}/metadata. A VM without
+// metadata returns an empty map.
+// Returns an error wrapping ErrNotFound if the VM does not exist, or
+// ErrNotSupported if the server does not store metadata.
+func (c *SlicerClient) GetVMMetadata(ctx context.Context, groupName, hostname string) (map[string]string, error) {
+	res, body, err := c.vmMetadataRequest(ctx, http.MethodGet, groupName, hostname, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]string{}
+	if len(body) > 0 {
+		if err := decodeJSONBody(res, body, &metadata); err != nil {
+			return nil, err
+		}
+	}
+	return metadata, nil
+}
+
+// SetVMMetadata replaces the key/value metadata of a VM with metadata,
+// e.g. owner, cost-center or ttl labels used for fleet governance. Keys
+// left out are removed; pass nil to clear all metadata. Tags are not
+// affected.
+// Returns an error wrapping ErrNotFound if the VM does not exist, or
+// ErrNotSupported if the server does not store metadata.
+func (c *SlicerClient) SetVMMetadata(ctx context.Context, groupName, hostname string, metadata map[string]string) error {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	for k := range metadata {
+		if k == "" {
+			return fmt.Errorf("metadata key must not be empty")
+		}
+	}
+	_, _, err := c.vmMetadataRequest(ctx, http.MethodPut, groupName, hostname, metadata)
+	return err
+}
+
+func (c *SlicerClient) vmMetadataRequest(ctx context.Context, method, groupName, hostname string, payload map[string]string) (*http.Response, []byte, error) {
+	endpoint := fmt.Sprintf("hostgroup/%s/nodes/%s/metadata", groupName, hostname)
+
+	var reqBody interface{}
+	if payload != nil {
+		reqBody = payload
+	}
+	res, err := c.makeJSONRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to access VM metadata: %w", err)
+	}
+
+	var body []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		body, _ = io.ReadAll(res.Body)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return res, body, nil
+	case http.StatusNotFound:
+		return nil, nil, fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, nil, fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotSupported)
+	}
+	return nil, nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
+}
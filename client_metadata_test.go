@@ -0,0 +1,57 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVMMetadata(t *testing.T) {
+	stored := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hostgroup/api/nodes/api-1/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			stored = map[string]string{}
+			json.NewDecoder(r.Body).Decode(&stored)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(stored)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	want := map[string]string{"owner": "alex", "cost-center": "42"}
+	if err := client.SetVMMetadata(ctx, "api", "api-1", want); err != nil {
+		t.Fatalf("SetVMMetadata() error = %v", err)
+	}
+	got, err := client.GetVMMetadata(ctx, "api", "api-1")
+	if err != nil {
+		t.Fatalf("GetVMMetadata() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Want %v, got %v", want, got)
+	}
+
+	if err := client.SetVMMetadata(ctx, "api", "api-1", nil); err != nil {
+		t.Fatalf("SetVMMetadata(nil) error = %v", err)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("Want metadata cleared, got %v", stored)
+	}
+
+	if _, err := client.GetVMMetadata(ctx, "api", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Want ErrNotFound, got %v", err)
+	}
+}
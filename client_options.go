@@ -0,0 +1,213 @@
+package slicer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a SlicerClient at construction time.
+type ClientOption func(*SlicerClient)
+
+// WithRoundTripper sends all requests through rt instead of the client's
+// transport. The configured http.Client is copied rather than modified, so
+// a shared client such as http.DefaultClient is left untouched.
+//
+// rt replaces the Unix socket transport too, so when baseURL is a socket
+// path rt is responsible for dialing it. This is mostly useful in tests,
+// e.g. with a RecordingTransport.
+func WithRoundTripper(rt http.RoundTripper) ClientOption {
+	return func(c *SlicerClient) {
+		hc := *c.httpClient
+		hc.Transport = rt
+		c.httpClient = &hc
+		c.ownsTransport = false
+		c.userTransport = true
+	}
+}
+
+// DefaultMaxLogLines is the largest lines value GetVMLogs accepts unless
+// the client is created with WithMaxLogLines.
+const DefaultMaxLogLines = 100000
+
+// WithMaxLogLines sets the largest lines value GetVMLogs accepts before
+// refusing the call, guarding against computed values that would buffer
+// gigabytes of logs. Zero or a negative n removes the limit.
+func WithMaxLogLines(n int) ClientOption {
+	return func(c *SlicerClient) {
+		c.maxLogLines = max(n, 0)
+	}
+}
+
+// WithMaxErrorBodySize sets how many bytes of an error response body are
+// kept in APIError.Body, instead of DefaultMaxErrorBodySize. Longer bodies
+// are cut, with APIError.Truncated set and a "[truncated]" marker added to
+// the error message, and are not read past the limit. A negative n keeps
+// whole bodies; zero keeps the default.
+func WithMaxErrorBodySize(n int) ClientOption {
+	return func(c *SlicerClient) {
+		c.maxErrorBody = n
+	}
+}
+
+// WithExecUnmarshal decodes each frame of an Exec or ExecWithReader
+// response with fn instead of encoding/json's Unmarshal, e.g. to plug in a
+// faster JSON library for very chatty commands. Frames are still split with
+// a json.Decoder; fn receives one complete JSON value at a time.
+func WithExecUnmarshal(fn func(data []byte, v any) error) ClientOption {
+	return func(c *SlicerClient) {
+		c.execUnmarshal = fn
+	}
+}
+
+// WithExecBufferSize gives the channels returned by Exec and
+// ExecWithReader room for n frames, so a burst of output does not stall
+// the network read while a batching consumer catches up. Once the buffer
+// is full the SDK stops reading the response until a frame is received,
+// applying backpressure to the command as with an unbuffered channel, but
+// cancelling the context still ends the stream promptly, even if the
+// consumer has stopped reading. Zero or a negative n keeps the channels
+// unbuffered.
+func WithExecBufferSize(n int) ClientOption {
+	return func(c *SlicerClient) {
+		c.execBufferSize = max(n, 0)
+	}
+}
+
+// WithDefaultCopyTimeout bounds copy and exec calls made with a context
+// that has no deadline, so a stalled transfer started with
+// context.Background() fails after d instead of hanging forever. It
+// applies to CpToVM, CpFromVM, Exec, ExecWithReader and ExecBuffered, and
+// their WithOptions variants; other API calls and contexts that already
+// carry a deadline are left alone. Zero or a negative d disables it.
+func WithDefaultCopyTimeout(d time.Duration) ClientOption {
+	return func(c *SlicerClient) {
+		c.copyTimeout = max(d, 0)
+	}
+}
+
+// copyContext derives a context bounded by WithDefaultCopyTimeout when ctx
+// has no deadline of its own. The returned cancel must always be called.
+func (c *SlicerClient) copyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.copyTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.copyTimeout)
+}
+
+// transportOptions holds the settings of the transport tuning options,
+// applied once all options have run.
+type transportOptions struct {
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+
+	rootCAs    *x509.CertPool
+	rootCAsErr error
+}
+
+// WithMaxIdleConnsPerHost sets how many idle keep-alive connections the
+// client keeps per host. net/http defaults to 2, which throttles many
+// concurrent calls against one Slicer server, e.g. CreateVMs or DeleteVMs
+// with a large concurrency.
+//
+// Like the other transport tuning options, it is ignored when the caller
+// supplies its own http.Client or RoundTripper; configure that transport
+// directly instead.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return func(c *SlicerClient) {
+		c.transportOpts.maxIdleConnsPerHost = n
+	}
+}
+
+// WithMaxConnsPerHost caps the total connections, idle or in use, the
+// client opens per host. Zero means no limit. It is ignored when the caller
+// supplies its own http.Client or RoundTripper.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return func(c *SlicerClient) {
+		c.transportOpts.maxConnsPerHost = n
+	}
+}
+
+// WithRootCAs trusts the CA certificates in pemCerts, in addition to the
+// system roots, when verifying the server's TLS certificate. It takes the
+// PEM directly so a CA injected through an environment variable need not be
+// written to a file first:
+//
+//	client := slicer.NewSlicerClient(url, token, ua, nil, slicer.WithRootCAs([]byte(os.Getenv("SLICER_CA"))))
+//
+// If pemCerts holds no valid certificate, every request fails with an error
+// saying so. It is ignored when the caller supplies its own http.Client or
+// RoundTripper.
+func WithRootCAs(pemCerts []byte) ClientOption {
+	return func(c *SlicerClient) {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			c.transportOpts.rootCAsErr = errors.New("slicer: WithRootCAs: no valid PEM certificates found")
+			return
+		}
+		c.transportOpts.rootCAs = pool
+	}
+}
+
+// errTransport fails every request, reporting an invalid client option at
+// the first call since options cannot return errors themselves.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// applyTransportOptions builds a tuned transport for the client, cloning
+// http.DefaultTransport or reusing the client's own Unix socket transport.
+func (c *SlicerClient) applyTransportOptions() {
+	if c.transportOpts == (transportOptions{}) || c.userTransport {
+		return
+	}
+
+	var t *http.Transport
+	switch rt := c.httpClient.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt
+	default:
+		return
+	}
+
+	if n := c.transportOpts.maxIdleConnsPerHost; n > 0 {
+		t.MaxIdleConnsPerHost = n
+		if t.MaxIdleConns > 0 && t.MaxIdleConns < n {
+			t.MaxIdleConns = n
+		}
+	}
+	if n := c.transportOpts.maxConnsPerHost; n > 0 {
+		t.MaxConnsPerHost = n
+	}
+	if pool := c.transportOpts.rootCAs; pool != nil {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		} else {
+			t.TLSClientConfig = t.TLSClientConfig.Clone()
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	hc := *c.httpClient
+	hc.Transport = t
+	if err := c.transportOpts.rootCAsErr; err != nil {
+		hc.Transport = errTransport{err: err}
+	}
+	c.httpClient = &hc
+	c.ownsTransport = true
+}
@@ -0,0 +1,91 @@
+package slicer
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportTuningOptions(t *testing.T) {
+	c := NewSlicerClient("http://slicer", "token", "test-agent", nil, WithMaxIdleConnsPerHost(64), WithMaxConnsPerHost(128))
+
+	tr, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Want a tuned *http.Transport, got %T", c.httpClient.Transport)
+	}
+	if tr.MaxIdleConnsPerHost != 64 || tr.MaxConnsPerHost != 128 {
+		t.Fatalf("Want 64 idle and 128 max conns per host, got %d and %d", tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost)
+	}
+	if tr == http.DefaultTransport {
+		t.Fatal("Want http.DefaultTransport cloned, not modified")
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Fatal("Want http.DefaultClient untouched")
+	}
+
+	custom := &http.Client{}
+	c = NewSlicerClient("http://slicer", "token", "test-agent", custom, WithMaxIdleConnsPerHost(64))
+	if c.httpClient != custom || custom.Transport != nil {
+		t.Fatal("Want tuning ignored for a caller-supplied client")
+	}
+}
+
+func TestWithRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `[]`)
+	}))
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	ctx := context.Background()
+
+	c := NewSlicerClient(server.URL, "token", "test-agent", nil, WithRootCAs(caPEM))
+	if _, err := c.GetHostGroups(ctx); err != nil {
+		t.Fatalf("Want request trusted with the custom CA, got %v", err)
+	}
+
+	c = NewSlicerClient(server.URL, "token", "test-agent", nil, WithMaxIdleConnsPerHost(4))
+	if _, err := c.GetHostGroups(ctx); err == nil {
+		t.Fatal("Want certificate error without the custom CA")
+	}
+
+	c = NewSlicerClient(server.URL, "token", "test-agent", nil, WithRootCAs([]byte("not a cert")))
+	if _, err := c.GetHostGroups(ctx); err == nil || !strings.Contains(err.Error(), "no valid PEM certificates") {
+		t.Fatalf("Want invalid PEM error, got %v", err)
+	}
+}
+
+func TestWithDefaultCopyTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stall like a hung transfer until the client gives up.
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := NewSlicerClient(server.URL, "token", "test-agent", nil, WithDefaultCopyTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := c.ExecBuffered(context.Background(), "vm-1", SlicerExecRequest{Command: "sleep"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Want deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Want ExecBuffered bounded by the default timeout, took %v", elapsed)
+	}
+
+	// A caller's own deadline is kept as-is.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	copyCtx, copyCancel := c.copyContext(ctx)
+	defer copyCancel()
+	if got, _ := copyCtx.Deadline(); !got.Equal(want) {
+		t.Fatalf("Want caller deadline %v kept, got %v", want, got)
+	}
+}
@@ -0,0 +1,49 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SetVMPersistent pins or releases an existing VM, via
+// PATCH /hostgroup/{groupName}/nodes/{hostname}/persistent, so an ephemeral
+// VM can be kept or a persistent one let go without recreating it.
+//
+// Returns an error wrapping ErrNotFound if the VM does not exist,
+// ErrNotSupported if the server cannot change persistence after creation,
+// or ErrConflict if it refuses to in the VM's current state, e.g. while it
+// is being deleted.
+func (c *SlicerClient) SetVMPersistent(ctx context.Context, groupName, hostname string, persistent bool) error {
+	endpoint := fmt.Sprintf("hostgroup/%s/nodes/%s/persistent", groupName, hostname)
+	request := struct {
+		Persistent bool `json:"persistent"`
+	}{Persistent: persistent}
+
+	res, err := c.makeJSONRequestWithContext(ctx, http.MethodPatch, endpoint, request)
+	if err != nil {
+		return fmt.Errorf("failed to set persistent: %w", err)
+	}
+
+	var body []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		body, _ = io.ReadAll(res.Body)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	case http.StatusConflict:
+		return fmt.Errorf("API request failed: persistence of %s cannot be changed in its current state: %w: %w", hostname, newAPIError(res, body), ErrConflict)
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotSupported)
+	}
+	return fmt.Errorf("API request failed: %w", newAPIError(res, body))
+}
@@ -0,0 +1,82 @@
+package slicer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetVMSSHKeys returns the SSH public keys currently authorized in the VM.
+// Returns an error wrapping ErrNotSupported if the agent does not support
+// key management, or ErrNotFound if the VM does not exist.
+func (c *SlicerClient) GetVMSSHKeys(ctx context.Context, hostname string) ([]string, error) {
+	u, err := c.vmURL(hostname, "ssh-keys", "")
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("slicer: GetVMSSHKeys: %w", err)
+	}
+	c.setCommonHeaders(httpReq)
+	res, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("slicer: GetVMSSHKeys: %w", err)
+	}
+	defer drainClose(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, readSSHKeysError(res, "GetVMSSHKeys")
+	}
+	var keys []string
+	if err := decodeJSONResponse(res, &keys); err != nil {
+		return nil, fmt.Errorf("slicer: GetVMSSHKeys: decode: %w", err)
+	}
+	return keys, nil
+}
+
+// SetVMSSHKeys replaces the SSH public keys authorized in the VM with keys.
+// An empty slice removes all keys. Returns an error wrapping
+// ErrNotSupported if the agent does not support key management.
+func (c *SlicerClient) SetVMSSHKeys(ctx context.Context, hostname string, keys []string) error {
+	u, err := c.vmURL(hostname, "ssh-keys", "")
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	body, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("slicer: SetVMSSHKeys: marshal: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slicer: SetVMSSHKeys: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setCommonHeaders(httpReq)
+	res, err := c.do(httpReq)
+	if err != nil {
+		return fmt.Errorf("slicer: SetVMSSHKeys: %w", err)
+	}
+	defer drainClose(res.Body)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return readSSHKeysError(res, "SetVMSSHKeys")
+	}
+	return nil
+}
+
+// readSSHKeysError maps the status codes an agent without key management
+// answers with onto ErrNotSupported.
+func readSSHKeysError(res *http.Response, op string) error {
+	err := readAPIError(res, op)
+	switch res.StatusCode {
+	case http.StatusNotImplemented, http.StatusMethodNotAllowed:
+		return fmt.Errorf("%w: %w", ErrNotSupported, err)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
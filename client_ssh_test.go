@@ -0,0 +1,101 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestVMSSHKeys_RoundTrip(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys = []string{"ssh-ed25519 AAAA alice"}
+		sent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vm/vm-1/ssh-keys" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(keys)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sent = append(sent, string(body))
+			_ = json.Unmarshal(body, &keys)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	got, err := client.GetVMSSHKeys(ctx, "vm-1")
+	if err != nil {
+		t.Fatalf("GetVMSSHKeys() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != "ssh-ed25519 AAAA alice" {
+		t.Fatalf("Want alice's key, got %v", got)
+	}
+
+	want := []string{"ssh-ed25519 AAAA alice", "ssh-ed25519 BBBB bob"}
+	if err := client.SetVMSSHKeys(ctx, "vm-1", want); err != nil {
+		t.Fatalf("SetVMSSHKeys() error = %v", err)
+	}
+	got, err = client.GetVMSSHKeys(ctx, "vm-1")
+	if err != nil {
+		t.Fatalf("GetVMSSHKeys() error = %v", err)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Want %v after set, got %v", want, got)
+	}
+
+	if err := client.SetVMSSHKeys(ctx, "vm-1", nil); err != nil {
+		t.Fatalf("SetVMSSHKeys(nil) error = %v", err)
+	}
+	if last := sent[len(sent)-1]; last != "[]" {
+		t.Fatalf("Want nil keys sent as [], got %s", last)
+	}
+}
+
+func TestVMSSHKeys_Errors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{status: http.StatusNotImplemented, want: ErrNotSupported},
+		{status: http.StatusMethodNotAllowed, want: ErrNotSupported},
+		{status: http.StatusNotFound, want: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", tt.status)
+			}))
+			defer server.Close()
+
+			client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+			ctx := context.Background()
+
+			if _, err := client.GetVMSSHKeys(ctx, "vm-1"); !errors.Is(err, tt.want) {
+				t.Fatalf("GetVMSSHKeys(): want %v, got %v", tt.want, err)
+			}
+			if err := client.SetVMSSHKeys(ctx, "vm-1", []string{"ssh-ed25519 AAAA"}); !errors.Is(err, tt.want) {
+				t.Fatalf("SetVMSSHKeys(): want %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
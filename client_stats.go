@@ -0,0 +1,144 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// StreamVMStats fetches stats for all VMs like GetVMStats(ctx, ""), but
+// decodes the response array one element at a time and delivers each
+// SlicerNodeStat as soon as it is decoded, rather than buffering the whole
+// fleet in a slice. This lowers peak memory and time to first result for
+// large fleets.
+//
+// An optional ListOptions applies the same Tag, TagPrefix and MaxStatAge
+// handling as GetVMStats; only the first opts entry is honored. A tag
+// filter costs one ListVMs request before the stream starts.
+//
+// The stats channel is closed at the end of the array. A request, API or
+// decode error is sent on the error channel, which is closed once the
+// stream ends. Cancel ctx to stop early.
+func (c *SlicerClient) StreamVMStats(ctx context.Context, opts ...ListOptions) (<-chan SlicerNodeStat, <-chan error) {
+	stats := make(chan SlicerNodeStat)
+	errs := make(chan error, 1)
+
+	filter := firstListOption(opts)
+	filter.Status = ""
+	filter.Secret = ""
+
+	go func() {
+		defer close(stats)
+		defer close(errs)
+
+		var matching map[string]bool
+		if filter.Tag != "" || filter.TagPrefix != "" {
+			nodes, err := c.ListVMs(ctx, filter)
+			if err != nil {
+				errs <- err
+				return
+			}
+			matching = make(map[string]bool, len(nodes))
+			for _, node := range nodes {
+				if filter.matchesTags(node.Tags) {
+					matching[node.Hostname] = true
+				}
+			}
+		}
+
+		req, err := c.newJSONRequest(ctx, http.MethodGet, "/nodes/stats", nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if q := filter.values(); len(q) > 0 {
+			req.URL.RawQuery = q.Encode()
+		}
+
+		res, err := c.do(req)
+		if err != nil {
+			errs <- fmt.Errorf("failed to perform GET request: %w", err)
+			return
+		}
+		defer drainClose(res.Body)
+
+		if res.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(res.Body)
+			errs <- fmt.Errorf("API request failed: %w", newAPIError(res, body))
+			return
+		}
+
+		dec := json.NewDecoder(res.Body)
+		tok, err := dec.Token()
+		if err != nil {
+			errs <- fmt.Errorf("failed to decode response: %w", err)
+			return
+		}
+		if tok == nil {
+			// A null body has no stats.
+			return
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			errs <- fmt.Errorf("failed to decode response: expected an array, got %v", tok)
+			return
+		}
+
+		for dec.More() {
+			var stat SlicerNodeStat
+			if err := dec.Decode(&stat); err != nil {
+				errs <- fmt.Errorf("failed to decode response: %w", err)
+				return
+			}
+			if matching != nil && !matching[stat.Hostname] {
+				continue
+			}
+			if filter.MaxStatAge > 0 {
+				stat.Stale = stat.Snapshot.IsStale(filter.MaxStatAge)
+			}
+			select {
+			case stats <- stat:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+
+		if _, err := dec.Token(); err != nil {
+			errs <- fmt.Errorf("failed to decode response: %w", err)
+		}
+	}()
+
+	return stats, errs
+}
+
+// GetVMStatsRaw returns the undecoded JSON body of the stats endpoint used
+// by GetVMStats, for consumers that parse or transform it with their own
+// streaming decoder. hostname selects a single VM; empty means all VMs.
+//
+// The caller must close the returned body. An unexpected status is
+// returned as an error instead, with the body already consumed.
+func (c *SlicerClient) GetVMStatsRaw(ctx context.Context, hostname string) (io.ReadCloser, error) {
+	endpoint := "/nodes/stats"
+	if hostname != "" {
+		endpoint = fmt.Sprintf("/node/%s/stats", hostname)
+	}
+
+	req, err := c.newJSONRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform GET request: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer drainClose(res.Body)
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("API request failed: %w", newAPIError(res, body))
+	}
+	return res.Body, nil
+}
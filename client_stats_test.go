@@ -0,0 +1,165 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamVMStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nodes/stats" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"hostname":"vm-1"},{"hostname":"vm-2"}]`))
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	stats, errs := client.StreamVMStats(context.Background())
+
+	var got []string
+	for stat := range stats {
+		got = append(got, stat.Hostname)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("StreamVMStats() error = %v", err)
+	}
+	if strings.Join(got, ",") != "vm-1,vm-2" {
+		t.Fatalf("Want vm-1,vm-2, got %v", got)
+	}
+}
+
+func TestStreamVMStats_DecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"hostname":"vm-1"},{"hostname":`))
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	stats, errs := client.StreamVMStats(context.Background())
+
+	var got []string
+	for stat := range stats {
+		got = append(got, stat.Hostname)
+	}
+	if len(got) != 1 || got[0] != "vm-1" {
+		t.Fatalf("Want vm-1 delivered before the error, got %v", got)
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "failed to decode response") {
+		t.Fatalf("Want decode error, got %v", err)
+	}
+}
+
+func TestStreamVMStats_Options(t *testing.T) {
+	old := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339Nano)
+	var statsQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/nodes":
+			w.Write([]byte(`[{"hostname":"vm-1","tags":["web"]},{"hostname":"vm-3","tags":["web"]}]`))
+		case "/nodes/stats":
+			statsQuery = r.URL.RawQuery
+			fmt.Fprintf(w, `[{"hostname":"vm-1","snapshot":{"timestamp":%q}},{"hostname":"vm-2"},{"hostname":"vm-3"}]`, old)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	stats, errs := client.StreamVMStats(context.Background(), ListOptions{Tag: "web", MaxStatAge: time.Minute})
+
+	var got []string
+	for stat := range stats {
+		if !stat.Stale {
+			t.Errorf("%s: want Stale", stat.Hostname)
+		}
+		got = append(got, stat.Hostname)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("StreamVMStats() error = %v", err)
+	}
+	if strings.Join(got, ",") != "vm-1,vm-3" {
+		t.Fatalf("Want vm-1,vm-3, got %v", got)
+	}
+	if statsQuery != "tag=web" {
+		t.Fatalf("Want tag=web sent to the stats endpoint, got %q", statsQuery)
+	}
+}
+
+func TestGetVMStatsRaw(t *testing.T) {
+	const payload = `[{"hostname":"vm-1"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Want auth header, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/node/vm-1/stats":
+			w.Write([]byte(payload))
+		default:
+			http.Error(w, "no such VM", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	body, err := client.GetVMStatsRaw(context.Background(), "vm-1")
+	if err != nil {
+		t.Fatalf("GetVMStatsRaw() error = %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil || string(got) != payload {
+		t.Fatalf("Want raw body %q, got %q (%v)", payload, got, err)
+	}
+
+	if _, err := client.GetVMStatsRaw(context.Background(), "vm-2"); err == nil || !strings.Contains(err.Error(), "no such VM") {
+		t.Fatalf("Want API error, got %v", err)
+	}
+}
+
+func TestGetVMStats_MaxStatAge(t *testing.T) {
+	fresh := time.Now().UTC().Format(time.RFC3339Nano)
+	old := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339Nano)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"hostname":"vm-1","snapshot":{"timestamp":%q}},{"hostname":"vm-2","snapshot":{"timestamp":%q}},{"hostname":"vm-3","error":"agent unreachable"}]`, fresh, old)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	stats, err := client.GetVMStats(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetVMStats() error = %v", err)
+	}
+	for _, stat := range stats {
+		if stat.Stale {
+			t.Fatalf("Want no entries flagged without MaxStatAge, got %s", stat.Hostname)
+		}
+	}
+
+	stats, err = client.GetVMStats(context.Background(), "", ListOptions{MaxStatAge: time.Minute})
+	if err != nil {
+		t.Fatalf("GetVMStats() error = %v", err)
+	}
+	want := map[string]bool{"vm-1": false, "vm-2": true, "vm-3": true}
+	for _, stat := range stats {
+		if stat.Stale != want[stat.Hostname] {
+			t.Errorf("%s: want Stale %v, got %v", stat.Hostname, want[stat.Hostname], stat.Stale)
+		}
+	}
+
+	if !stats[1].Snapshot.IsStale(time.Minute) || stats[1].Snapshot.IsStale(time.Hour) {
+		t.Fatalf("IsStale() did not honour maxAge for a 10 minute old snapshot")
+	}
+}
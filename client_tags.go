@@ -0,0 +1,157 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SlicerUpdateTagsRequest adds and removes tags on a VM. Tags in both
+// lists are removed.
+type SlicerUpdateTagsRequest struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// ReconcileTagsOptions controls ReconcileTagsWithOptions.
+type ReconcileTagsOptions struct {
+	// ManagedPrefix limits reconciliation to tags starting with this
+	// prefix, e.g. "myctl/". Other tags are left alone, so several
+	// controllers can each own a subset of a VM's tags. Empty manages all
+	// tags.
+	ManagedPrefix string
+}
+
+// UpdateVMTags adds and removes tags on a VM without replacing the rest,
+// via PATCH /hostgroup/{groupName}/nodes/{hostname}/tags.
+// Returns an error wrapping ErrNotFound if the VM does not exist, or
+// ErrNotSupported if the server cannot update tags.
+func (c *SlicerClient) UpdateVMTags(ctx context.Context, groupName, hostname string, request SlicerUpdateTagsRequest) error {
+	endpoint := fmt.Sprintf("hostgroup/%s/nodes/%s/tags", groupName, hostname)
+	res, err := c.makeJSONRequestWithContext(ctx, http.MethodPatch, endpoint, request)
+	if err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	var body []byte
+	if res.Body != nil {
+		defer func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}()
+		body, _ = io.ReadAll(res.Body)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotFound)
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("API request failed: %w: %w", newAPIError(res, body), ErrNotSupported)
+	}
+	return fmt.Errorf("API request failed: %w", newAPIError(res, body))
+}
+
+// ReconcileTags brings a VM's tags in line with desired, applying only the
+// tags to add and remove with UpdateVMTags. No update is sent when the tags
+// already match. All of the VM's tags are managed; use
+// ReconcileTagsWithOptions with a ManagedPrefix to touch only a subset.
+func (c *SlicerClient) ReconcileTags(ctx context.Context, groupName, hostname string, desired []string) error {
+	return c.ReconcileTagsWithOptions(ctx, groupName, hostname, desired, ReconcileTagsOptions{})
+}
+
+// ReconcileTagsWithOptions is like ReconcileTags but takes a
+// ReconcileTagsOptions, e.g. to manage only tags under a ManagedPrefix.
+// Every desired tag must carry the prefix.
+//
+// The current tags are read from GetHostGroupNodes. Returns an error
+// wrapping ErrNotFound if the VM is not in groupName.
+func (c *SlicerClient) ReconcileTagsWithOptions(ctx context.Context, groupName, hostname string, desired []string, options ReconcileTagsOptions) error {
+	for _, tag := range desired {
+		if !strings.HasPrefix(tag, options.ManagedPrefix) {
+			return fmt.Errorf("slicer: ReconcileTags: desired tag %q is outside the managed prefix %q", tag, options.ManagedPrefix)
+		}
+	}
+
+	nodes, err := c.GetHostGroupNodes(ctx, groupName)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(nodes, func(n SlicerNode) bool { return n.Hostname == hostname })
+	if i < 0 {
+		return fmt.Errorf("slicer: ReconcileTags: VM %s not found in host group %s: %w", hostname, groupName, ErrNotFound)
+	}
+
+	add, remove := DiffTags(nodes[i].Tags, desired, options.ManagedPrefix)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	return c.UpdateVMTags(ctx, groupName, hostname, SlicerUpdateTagsRequest{Add: add, Remove: remove})
+}
+
+// DiffTags returns the tags to add to current and remove from it so that
+// the tags starting with managedPrefix equal desired. Tags without the
+// prefix are never removed; an empty prefix manages every tag. Both
+// results are sorted and free of duplicates.
+func DiffTags(current, desired []string, managedPrefix string) (add, remove []string) {
+	for _, tag := range desired {
+		if !slices.Contains(current, tag) {
+			add = append(add, tag)
+		}
+	}
+	for _, tag := range current {
+		if strings.HasPrefix(tag, managedPrefix) && !slices.Contains(desired, tag) {
+			remove = append(remove, tag)
+		}
+	}
+	slices.Sort(add)
+	slices.Sort(remove)
+	return slices.Compact(add), slices.Compact(remove)
+}
+
+// GetAllTags returns the tags of every VM in the fleet, keyed by hostname,
+// as computed by TagsByHost from ListVMs. Use HostsByTag on the ListVMs
+// output for the reverse view.
+func (c *SlicerClient) GetAllTags(ctx context.Context) (map[string][]string, error) {
+	nodes, err := c.ListVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return TagsByHost(nodes), nil
+}
+
+// TagsByHost maps each node's hostname to its tags, sorted and without
+// duplicates. Nodes without tags map to an empty slice, so every hostname
+// is present.
+func TagsByHost(nodes []SlicerNode) map[string][]string {
+	out := make(map[string][]string, len(nodes))
+	for _, n := range nodes {
+		tags := append(out[n.Hostname], n.Tags...)
+		slices.Sort(tags)
+		out[n.Hostname] = slices.Compact(tags)
+		if out[n.Hostname] == nil {
+			out[n.Hostname] = []string{}
+		}
+	}
+	return out
+}
+
+// HostsByTag maps each tag to the hostnames of the nodes carrying it,
+// sorted and without duplicates, e.g. for an inventory keyed by role.
+func HostsByTag(nodes []SlicerNode) map[string][]string {
+	out := map[string][]string{}
+	for _, n := range nodes {
+		for _, tag := range n.Tags {
+			out[tag] = append(out[tag], n.Hostname)
+		}
+	}
+	for tag, hosts := range out {
+		slices.Sort(hosts)
+		out[tag] = slices.Compact(hosts)
+	}
+	return out
+}
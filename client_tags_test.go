@@ -0,0 +1,97 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTags(t *testing.T) {
+	current := []string{"env=dev", "myctl/a", "myctl/b", "other/x"}
+	desired := []string{"myctl/b", "myctl/c", "myctl/c"}
+
+	add, remove := DiffTags(current, desired, "myctl/")
+	if !reflect.DeepEqual(add, []string{"myctl/c"}) {
+		t.Fatalf("Want add [myctl/c], got %v", add)
+	}
+	if !reflect.DeepEqual(remove, []string{"myctl/a"}) {
+		t.Fatalf("Want remove [myctl/a], got %v", remove)
+	}
+
+	_, remove = DiffTags(current, desired, "")
+	if !reflect.DeepEqual(remove, []string{"env=dev", "myctl/a", "other/x"}) {
+		t.Fatalf("Want every undesired tag removed without a prefix, got %v", remove)
+	}
+}
+
+func TestReconcileTags(t *testing.T) {
+	var updates []SlicerUpdateTagsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/hostgroup/api/nodes":
+			json.NewEncoder(w).Encode([]SlicerNode{{Hostname: "api-1", Tags: []string{"env=dev", "myctl/a"}}})
+		case r.Method == http.MethodPatch && r.URL.Path == "/hostgroup/api/nodes/api-1/tags":
+			var req SlicerUpdateTagsRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			updates = append(updates, req)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	err := client.ReconcileTagsWithOptions(ctx, "api", "api-1", []string{"myctl/b"}, ReconcileTagsOptions{ManagedPrefix: "myctl/"})
+	if err != nil {
+		t.Fatalf("ReconcileTagsWithOptions() error = %v", err)
+	}
+	want := []SlicerUpdateTagsRequest{{Add: []string{"myctl/b"}, Remove: []string{"myctl/a"}}}
+	if !reflect.DeepEqual(updates, want) {
+		t.Fatalf("Want %v, got %v", want, updates)
+	}
+
+	// Tags already in place need no update.
+	if err := client.ReconcileTagsWithOptions(ctx, "api", "api-1", []string{"myctl/a"}, ReconcileTagsOptions{ManagedPrefix: "myctl/"}); err != nil {
+		t.Fatalf("ReconcileTagsWithOptions() error = %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Want no update when tags match, got %v", updates)
+	}
+
+	if err := client.ReconcileTagsWithOptions(ctx, "api", "api-1", []string{"env=prod"}, ReconcileTagsOptions{ManagedPrefix: "myctl/"}); err == nil {
+		t.Fatal("Want error for desired tag outside the managed prefix")
+	}
+}
+
+func TestTagIndexes(t *testing.T) {
+	nodes := []SlicerNode{
+		{Hostname: "vm-2", Tags: []string{"web", "prod"}},
+		{Hostname: "vm-1", Tags: []string{"web", "web"}},
+		{Hostname: "vm-3"},
+	}
+
+	byHost := TagsByHost(nodes)
+	wantByHost := map[string][]string{
+		"vm-1": {"web"},
+		"vm-2": {"prod", "web"},
+		"vm-3": {},
+	}
+	if !reflect.DeepEqual(byHost, wantByHost) {
+		t.Fatalf("TagsByHost() = %v, want %v", byHost, wantByHost)
+	}
+
+	byTag := HostsByTag(nodes)
+	wantByTag := map[string][]string{
+		"web":  {"vm-1", "vm-2"},
+		"prod": {"vm-2"},
+	}
+	if !reflect.DeepEqual(byTag, wantByTag) {
+		t.Fatalf("HostsByTag() = %v, want %v", byTag, wantByTag)
+	}
+}
@@ -1,13 +1,21 @@
 package slicer
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -268,3 +276,939 @@ func TestCreateVMWithOptions_InvalidWait(t *testing.T) {
 		t.Fatal("Want invalid wait error, got nil")
 	}
 }
+
+func TestPatchSecret_IfMatchConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-Match"); got != `"v1"` {
+			t.Errorf("Want If-Match %q, got %q", `"v1"`, got)
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	err := client.PatchSecret(context.Background(), "api-key", UpdateSecretRequest{
+		IfMatch: `"v1"`,
+		Data:    "new",
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Want ErrConflict, got %v", err)
+	}
+}
+
+func TestCreateSecret_Compress(t *testing.T) {
+	data := strings.Repeat("-----BEGIN CERTIFICATE-----\n", 1000)
+
+	for _, supported := range []bool{true, false} {
+		var encoding string
+		var got CreateSecretRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/capabilities" {
+				_, _ = fmt.Fprintf(w, `{"version":"0.1.0","compressed_secrets":%v}`, supported)
+				return
+			}
+			encoding = r.Header.Get("Content-Encoding")
+			var body io.Reader = r.Body
+			if encoding == "gzip" {
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("failed to read gzip body: %v", err)
+					return
+				}
+				body = zr
+			}
+			_ = json.NewDecoder(body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+		err := client.CreateSecret(context.Background(), CreateSecretRequest{Name: "chain", Data: data, Compress: true})
+		server.Close()
+		if err != nil {
+			t.Fatalf("CreateSecret() error = %v", err)
+		}
+
+		if want := map[bool]string{true: "gzip", false: ""}[supported]; encoding != want {
+			t.Fatalf("supported=%v: want Content-Encoding %q, got %q", supported, want, encoding)
+		}
+		if got.Name != "chain" || got.Data != data {
+			t.Fatalf("supported=%v: want the secret delivered intact, got %q with %d bytes", supported, got.Name, len(got.Data))
+		}
+	}
+}
+
+func TestGetSecret_CapturesETag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/secrets/api-key" {
+			t.Errorf("Want path /secrets/api-key, got %s", r.URL.Path)
+		}
+		w.Header().Set("ETag", `"v2"`)
+		_, _ = io.WriteString(w, `{"name":"api-key","size":3,"permissions":"0600"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	secret, err := client.GetSecret(context.Background(), "api-key")
+	if err != nil {
+		t.Fatalf("GetSecret() error = %v", err)
+	}
+	if secret.ETag != `"v2"` {
+		t.Fatalf("Want ETag %q, got %q", `"v2"`, secret.ETag)
+	}
+}
+
+func TestDeleteVMWithOptions_ForceQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hostgroup/vm/nodes/vm-1" {
+			t.Errorf("Want path /hostgroup/vm/nodes/vm-1, got %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("force"); got != "true" {
+			t.Errorf("Want force=true, got %q", got)
+		}
+		_, _ = io.WriteString(w, `{"message":"deleted","disk_removed":"true"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	resp, err := client.DeleteVMWithOptions(context.Background(), "vm", "vm-1", SlicerDeleteVMOptions{Force: true})
+	if err != nil {
+		t.Fatalf("DeleteVMWithOptions() error = %v", err)
+	}
+	if resp.DiskRemoved != "true" {
+		t.Fatalf("Want DiskRemoved true, got %q", resp.DiskRemoved)
+	}
+}
+
+func TestDeleteNode_UsesDeleteVM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("Want DELETE, got %s", r.Method)
+		}
+		if r.URL.Path == "/hostgroup/vm/nodes/gone" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	if err := client.DeleteNode("vm", "vm-1"); err != nil {
+		t.Fatalf("DeleteNode() error = %v", err)
+	}
+	if err := client.DeleteNode("vm", "gone"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Want ErrNotFound as for DeleteVM, got %v", err)
+	}
+}
+
+func TestWithRoundTripper_RecordsRequests(t *testing.T) {
+	rt := &RecordingTransport{}
+	client := NewSlicerClient("http://slicer.example", "token", "test-agent", nil, WithRoundTripper(rt))
+
+	if err := client.PauseVM(context.Background(), "vm-1"); err != nil {
+		t.Fatalf("PauseVM() error = %v", err)
+	}
+
+	reqs := rt.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("Want 1 recorded request, got %d", len(reqs))
+	}
+	if reqs[0].Method != http.MethodPost || reqs[0].URL.Path != "/vm/vm-1/pause" {
+		t.Fatalf("Want POST /vm/vm-1/pause, got %s %s", reqs[0].Method, reqs[0].URL.Path)
+	}
+	if got := reqs[0].Header.Get("Authorization"); got != "Bearer token" {
+		t.Fatalf("Want Authorization %q, got %q", "Bearer token", got)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Fatal("Want http.DefaultClient left untouched")
+	}
+}
+
+func TestCreateVMStream_FallsBackToSingleResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("stream"); got != "true" {
+			t.Errorf("Want stream=true, got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"hostname":"vm-1","ip":"192.168.1.10/24"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	events, err := client.CreateVMStream(context.Background(), "vm", SlicerCreateNodeRequest{})
+	if err != nil {
+		t.Fatalf("CreateVMStream() error = %v", err)
+	}
+
+	var got []ProvisionEvent
+	for evt := range events {
+		got = append(got, evt)
+	}
+	if len(got) != 1 || got[0].Phase != ProvisionPhaseReady || got[0].Node == nil || got[0].Node.Hostname != "vm-1" {
+		t.Fatalf("Want a single ready event for vm-1, got %#v", got)
+	}
+}
+
+func TestCreateVMStream_StreamsPhases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, `{"phase":"booting"}`+"\n")
+		_, _ = io.WriteString(w, `{"phase":"assigning_ip"}`+"\n")
+		_, _ = io.WriteString(w, `{"phase":"ready","node":{"hostname":"vm-1"}}`+"\n")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	events, err := client.CreateVMStream(context.Background(), "vm", SlicerCreateNodeRequest{})
+	if err != nil {
+		t.Fatalf("CreateVMStream() error = %v", err)
+	}
+
+	var phases []string
+	for evt := range events {
+		phases = append(phases, evt.Phase)
+	}
+	want := []string{ProvisionPhaseBooting, ProvisionPhaseAssigning, ProvisionPhaseReady}
+	if len(phases) != len(want) {
+		t.Fatalf("Want phases %v, got %v", want, phases)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Fatalf("Want phases %v, got %v", want, phases)
+		}
+	}
+}
+
+func TestCreateVMStreamWithOptions(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, `{"phase":"ready","node":{"hostname":"vm-1"}}`+"\n")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	_, err := client.CreateVMStreamWithOptions(ctx, "vm", SlicerCreateNodeRequest{Userdata: "#cloud-config\n- curl\n"}, SlicerCreateNodeOptions{ValidateUserdata: true})
+	if err == nil || query != nil {
+		t.Fatalf("Want invalid userdata refused before the request, got %v", err)
+	}
+
+	events, err := client.CreateVMStreamWithOptions(ctx, "vm", SlicerCreateNodeRequest{}, SlicerCreateNodeOptions{Wait: SlicerCreateNodeWaitAgent})
+	if err != nil {
+		t.Fatalf("CreateVMStreamWithOptions() error = %v", err)
+	}
+	for range events {
+	}
+	if query.Get("stream") != "true" || query.Get("wait") != "agent" {
+		t.Fatalf("Want stream=true and wait=agent, got %v", query)
+	}
+}
+
+func TestAPIError_UnwrapsAuthSentinels(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{status: http.StatusUnauthorized, want: ErrUnauthorized},
+		{status: http.StatusForbidden, want: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			_, _ = io.WriteString(w, "token rejected\n")
+		}))
+
+		client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+		_, err := client.ListVMs(context.Background())
+		server.Close()
+
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("status %d: want errors.Is(err, %v), got %v", tt.status, tt.want, err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("status %d: want *APIError in chain, got %T", tt.status, err)
+		}
+		if apiErr.StatusCode != tt.status || apiErr.Body != "token rejected" {
+			t.Fatalf("status %d: unexpected APIError %+v", tt.status, apiErr)
+		}
+	}
+}
+
+func TestDeleteSecretIfExists_IgnoresNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/secrets/gone" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, "secret not found")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	if err := client.DeleteSecret(context.Background(), "gone"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteSecret() want ErrNotFound, got %v", err)
+	}
+	if err := client.DeleteSecretIfExists(context.Background(), "gone"); err != nil {
+		t.Fatalf("DeleteSecretIfExists() want nil, got %v", err)
+	}
+}
+
+func TestGetHostGroups_NonJSONResponseKeepsBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/json" {
+			t.Errorf("Want Accept application/json, got %q", got)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, "<html>Bad Gateway</html>")
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	_, err := client.GetHostGroups(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Want *APIError, got %v", err)
+	}
+	if apiErr.ContentType != "text/html" || apiErr.Body != "<html>Bad Gateway</html>" {
+		t.Fatalf("Unexpected APIError %+v", apiErr)
+	}
+}
+
+func TestListVMsByState_FiltersClientSide(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("status"); got != NodeStatusStopped {
+			t.Errorf("Want status=%s, got %q", NodeStatusStopped, got)
+		}
+		_, _ = io.WriteString(w, `[{"hostname":"vm-1","status":"Running"},{"hostname":"vm-2","status":"stopped"},{"hostname":"vm-3"}]`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	nodes, err := client.ListVMsByState(context.Background(), NodeStatusStopped)
+	if err != nil {
+		t.Fatalf("ListVMsByState() error = %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Hostname != "vm-2" {
+		t.Fatalf("Want only vm-2, got %#v", nodes)
+	}
+}
+
+func TestListVMsUsingSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		caps string
+		body string
+		want []string
+	}{
+		{
+			name: "reports secrets",
+			caps: `{"version":"0.1.0"}`,
+			body: `[{"hostname":"vm-1","secrets":["db"]},{"hostname":"vm-2","secrets":["api"]},{"hostname":"vm-3"}]`,
+			want: []string{"vm-1"},
+		},
+		{
+			name: "no VMs",
+			caps: `{"version":"0.1.0"}`,
+			body: `[]`,
+		},
+		{
+			name: "server filter",
+			caps: `{"version":"0.1.0","secret_filter":true}`,
+			body: `[{"hostname":"vm-1"},{"hostname":"vm-2"}]`,
+			want: []string{"vm-1", "vm-2"},
+		},
+		{
+			name: "secrets not reported",
+			caps: `{"version":"0.1.0"}`,
+			body: `[{"hostname":"vm-1"},{"hostname":"vm-2"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/capabilities" {
+					_, _ = io.WriteString(w, tt.caps)
+					return
+				}
+				if got := r.URL.Query().Get("secret"); got != "db" {
+					t.Errorf("Want secret=db, got %q", got)
+				}
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+			nodes, err := client.ListVMsUsingSecret(context.Background(), "db")
+			if err != nil {
+				t.Fatalf("ListVMsUsingSecret() error = %v", err)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.Hostname)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetVMLogs_MaxLinesCap(t *testing.T) {
+	rt := &RecordingTransport{Respond: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"hostname":"vm-1","lines":1,"content":"boot\n"}`)),
+			Request:    req,
+		}, nil
+	}}
+
+	client := NewSlicerClient("http://slicer", "token", "test-agent", nil, WithRoundTripper(rt), WithMaxLogLines(500))
+	ctx := context.Background()
+
+	if _, err := client.GetVMLogs(ctx, "vm-1", 501); err == nil || !strings.Contains(err.Error(), "exceeds the limit of 500") {
+		t.Fatalf("Want limit error, got %v", err)
+	}
+	if n := len(rt.Requests()); n != 0 {
+		t.Fatalf("Want no request sent, got %d", n)
+	}
+
+	for _, lines := range []int{500, -1} {
+		if _, err := client.GetVMLogs(ctx, "vm-1", lines); err != nil {
+			t.Fatalf("GetVMLogs(%d) error = %v", lines, err)
+		}
+	}
+}
+
+func TestRenameSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{name: "renamed", status: http.StatusOK},
+		{name: "missing", status: http.StatusNotFound, wantErr: ErrNotFound},
+		{name: "taken", status: http.StatusConflict, wantErr: ErrSecretExists},
+		{name: "unsupported", status: http.StatusNotImplemented, wantErr: ErrNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/secrets/old/rename" {
+					t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				var body struct {
+					Name string `json:"name"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name != "new" {
+					t.Errorf("Want name=new in body, got %q (%v)", body.Name, err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+			err := client.RenameSecret(context.Background(), "old", "new")
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("RenameSecret() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestListVMs_DecodesPersistenceFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `[{"hostname":"vm-1","persistent":true,"disk_image":"/var/lib/slicer/vm-1.img"},{"hostname":"vm-2"}]`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	nodes, err := client.ListVMs(context.Background())
+	if err != nil {
+		t.Fatalf("ListVMs() error = %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("Want 2 nodes, got %d", len(nodes))
+	}
+	if !nodes[0].Persistent || nodes[0].DiskImage != "/var/lib/slicer/vm-1.img" {
+		t.Fatalf("Want persistent vm-1 with disk image, got %#v", nodes[0])
+	}
+	if nodes[1].Persistent || nodes[1].DiskImage != "" {
+		t.Fatalf("Want ephemeral vm-2, got %#v", nodes[1])
+	}
+}
+
+func TestGetVMStats_FiltersByTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nodes/stats":
+			if got := r.URL.Query().Get("tag"); got != "web" {
+				t.Errorf("Want tag=web, got %q", got)
+			}
+			_, _ = io.WriteString(w, `[{"hostname":"web-1"},{"hostname":"db-1"},{"hostname":"web-2"}]`)
+		case "/nodes":
+			_, _ = io.WriteString(w, `[{"hostname":"web-1","tags":["web"]},{"hostname":"db-1","tags":["db"]},{"hostname":"web-2","tags":["web","canary"]}]`)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	stats, err := client.GetVMStats(context.Background(), "", ListOptions{Tag: "web"})
+	if err != nil {
+		t.Fatalf("GetVMStats() error = %v", err)
+	}
+	var got []string
+	for _, s := range stats {
+		got = append(got, s.Hostname)
+	}
+	if !slices.Equal(got, []string{"web-1", "web-2"}) {
+		t.Fatalf("Want web-1 and web-2, got %v", got)
+	}
+}
+
+func TestSlicerAgentHealthResponse_JSONRoundTrip(t *testing.T) {
+	in := SlicerAgentHealthResponse{
+		Hostname:     "vm-1",
+		AgentUptime:  72*time.Hour + 3*time.Minute,
+		AgentVersion: "0.1.0",
+		SystemUptime: 90 * time.Second,
+		UserdataRan:  true,
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"agent_uptime":"72h3m"`) || !strings.Contains(string(data), `"system_uptime":"1m30s"`) {
+		t.Fatalf("Want durations as strings, got %s", data)
+	}
+
+	var out SlicerAgentHealthResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out != in {
+		t.Fatalf("Round trip mismatch: got %#v, want %#v", out, in)
+	}
+
+	if err := json.Unmarshal([]byte(`{"agent_uptime":5000000000}`), &out); err != nil {
+		t.Fatalf("Unmarshal() of nanoseconds error = %v", err)
+	}
+	if out.AgentUptime != 5*time.Second {
+		t.Fatalf("Want 5s from nanoseconds, got %v", out.AgentUptime)
+	}
+
+	if err := json.Unmarshal([]byte(`{"agent_uptime":"soon"}`), &out); err == nil {
+		t.Fatal("Want error for invalid duration")
+	}
+}
+
+func TestVMExists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("Want %s method, got %s", http.MethodHead, r.Method)
+		}
+		switch r.URL.Path {
+		case "/vm/vm-1/health":
+			w.WriteHeader(http.StatusOK)
+		case "/vm/vm-2/health":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	if ok, err := client.VMExists(ctx, "vm-1"); err != nil || !ok {
+		t.Fatalf("Want vm-1 to exist, got %v, %v", ok, err)
+	}
+	if ok, err := client.VMExists(ctx, "vm-2"); err != nil || ok {
+		t.Fatalf("Want vm-2 missing, got %v, %v", ok, err)
+	}
+	if _, err := client.VMExists(ctx, "vm-3"); err == nil {
+		t.Fatal("Want error for an unexpected status")
+	}
+}
+
+func TestSortNodesByAge(t *testing.T) {
+	now := time.Now()
+	nodes := []SlicerNode{
+		{Hostname: "unknown"},
+		{Hostname: "new", CreatedAt: now.Add(-time.Minute)},
+		{Hostname: "old-b", CreatedAt: now.Add(-time.Hour)},
+		{Hostname: "old-a", CreatedAt: now.Add(-time.Hour)},
+	}
+
+	SortNodesByAge(nodes)
+
+	var got []string
+	for _, n := range nodes {
+		got = append(got, n.Hostname)
+	}
+	if strings.Join(got, ",") != "old-a,old-b,new,unknown" {
+		t.Fatalf("Want oldest first with unknown last, got %v", got)
+	}
+	if age := nodes[0].Age(); age < time.Hour {
+		t.Fatalf("Want age of at least an hour, got %v", age)
+	}
+	if age := nodes[3].Age(); age != 0 {
+		t.Fatalf("Want zero age without CreatedAt, got %v", age)
+	}
+}
+
+func TestCreateVM_DiskSize(t *testing.T) {
+	var got SlicerCreateNodeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = SlicerCreateNodeRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		if len(got.Tags) > 0 {
+			http.Error(w, "invalid tag", http.StatusBadRequest)
+			return
+		}
+		if !got.Persistent {
+			http.Error(w, "disk_size_gb requires a persistent VM", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(SlicerCreateNodeResponse{Hostname: "vm-1"})
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	if _, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{DiskSizeGB: 50, Persistent: true}); err != nil {
+		t.Fatalf("CreateVM() error = %v", err)
+	}
+	if got.DiskSizeGB != 50 {
+		t.Fatalf("Want disk_size_gb 50 sent, got %d", got.DiskSizeGB)
+	}
+
+	_, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{DiskSizeGB: 50})
+	if err == nil || !strings.Contains(err.Error(), "non-persistent") {
+		t.Fatalf("Want a clear error for a non-persistent VM, got %v", err)
+	}
+
+	_, err = client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{DiskSizeGB: 50, Tags: []string{"bad tag"}})
+	if err == nil || strings.Contains(err.Error(), "DiskSizeGB") || !strings.Contains(err.Error(), "invalid tag") {
+		t.Fatalf("Want the server's error for an unrelated rejection, got %v", err)
+	}
+
+	if _, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{DiskSizeGB: -1}); err == nil {
+		t.Fatal("Want error for a negative disk size")
+	}
+}
+
+func TestCreateVM_TTL(t *testing.T) {
+	var raw map[string]any
+	var deleted atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/capabilities":
+			_, _ = io.WriteString(w, `{"version":"0.1.0"}`)
+		case r.Method == http.MethodDelete:
+			deleted.Add(1)
+			json.NewEncoder(w).Encode(SlicerDeleteResponse{})
+		default:
+			raw = nil
+			json.NewDecoder(r.Body).Decode(&raw)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(SlicerCreateNodeResponse{Hostname: "vm-1"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	if _, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{TTL: 90 * time.Minute}); err != nil {
+		t.Fatalf("CreateVM() error = %v", err)
+	}
+	if raw["ttl"] != "1h30m" {
+		t.Fatalf("Want ttl 1h30m sent, got %v", raw["ttl"])
+	}
+
+	var req SlicerCreateNodeRequest
+	if err := json.Unmarshal([]byte(`{"cpus":2,"ttl":"10m"}`), &req); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if req.CPUs != 2 || req.TTL != 10*time.Minute {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+
+	if _, err := client.GetCapabilities(ctx); err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	_, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{TTL: time.Hour})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported without server TTL, got %v", err)
+	}
+
+	if err := <-client.ExpireVMAfter(ctx, "vm", "vm-1", time.Millisecond); err != nil {
+		t.Fatalf("ExpireVMAfter() error = %v", err)
+	}
+	if got := deleted.Load(); got != 1 {
+		t.Fatalf("Want 1 delete, got %d", got)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	result := client.ExpireVMAfter(cancelled, "vm", "vm-1", time.Hour)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Want context.Canceled, got %v", err)
+	}
+	if got := deleted.Load(); got != 1 {
+		t.Fatalf("Want no delete after cancel, got %d", got)
+	}
+}
+
+func TestNodeNetwork(t *testing.T) {
+	res := SlicerCreateNodeResponse{IP: "192.168.137.2/24", Gateway: "192.168.137.1"}
+	if got := res.IPAddress().String(); got != "192.168.137.2" {
+		t.Fatalf("Want IP 192.168.137.2, got %s", got)
+	}
+	if got := res.Network().String(); got != "192.168.137.0/24" {
+		t.Fatalf("Want network 192.168.137.0/24, got %s", got)
+	}
+	if got := res.GatewayAddress().String(); got != "192.168.137.1" {
+		t.Fatalf("Want gateway 192.168.137.1, got %s", got)
+	}
+
+	node := SlicerNode{IP: "10.0.0.5"}
+	if got := node.IPAddress().String(); got != "10.0.0.5" {
+		t.Fatalf("Want IP 10.0.0.5, got %s", got)
+	}
+	if node.Network() != nil || node.GatewayAddress() != nil {
+		t.Fatalf("Want no network or gateway for a bare address, got %v and %v", node.Network(), node.GatewayAddress())
+	}
+}
+
+func TestWithUserAgentSuffix(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+		json.NewEncoder(w).Encode([]SlicerNode{})
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "my-tool/1.0", nil)
+	ctx := context.Background()
+
+	if _, err := client.ListVMs(WithUserAgentSuffix(ctx, "op=list")); err != nil {
+		t.Fatalf("ListVMs() error = %v", err)
+	}
+	if _, err := client.ListVMs(WithUserAgentSuffix(WithUserAgentSuffix(ctx, "op=sync"), "run=7")); err != nil {
+		t.Fatalf("ListVMs() error = %v", err)
+	}
+	if _, err := client.ListVMs(ctx); err != nil {
+		t.Fatalf("ListVMs() error = %v", err)
+	}
+
+	want := []string{"my-tool/1.0; op=list", "my-tool/1.0; op=sync; run=7", "my-tool/1.0"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Want User-Agents %q, got %q", want, got)
+	}
+}
+
+// TestSlicerClient_MethodsTakeContext guards the convention that every
+// method that may talk to the server takes a context as its first argument.
+func TestSlicerClient_MethodsTakeContext(t *testing.T) {
+	exempt := map[string]bool{
+		"Close":      true, // releases idle connections, no request
+		"DeleteNode": true, // deprecated in favour of DeleteVM
+	}
+	ctxType := reflect.TypeOf((*context.Context)(nil)).Elem()
+
+	typ := reflect.TypeOf(&SlicerClient{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		if exempt[m.Name] {
+			continue
+		}
+		// In[0] is the receiver.
+		if m.Type.NumIn() < 2 || m.Type.In(1) != ctxType {
+			t.Errorf("%s does not take a context.Context as its first argument", m.Name)
+		}
+	}
+}
+
+func TestSlicerDeleteResponse_DiskRemovedBytes(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int64
+		wantOK bool
+	}{
+		{in: "1048576", want: 1 << 20, wantOK: true},
+		{in: "12 GiB", want: 12 << 30, wantOK: true},
+		{in: "1.5G", want: 3 << 29, wantOK: true},
+		{in: "512MB", want: 512 << 20, wantOK: true},
+		{in: "4096 bytes", want: 4096, wantOK: true},
+		{in: "2 kib", want: 2048, wantOK: true},
+		{in: "true"},
+		{in: ""},
+		{in: "12 parsecs"},
+	}
+	for _, tt := range tests {
+		resp := SlicerDeleteResponse{DiskRemoved: tt.in}
+		got, ok := resp.DiskRemovedBytes()
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("DiskRemovedBytes(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSetVMPersistent(t *testing.T) {
+	var got map[string]bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hostgroup/api/nodes/api-1/persistent":
+			if r.Method != http.MethodPatch {
+				t.Errorf("Want PATCH, got %s", r.Method)
+			}
+			got = nil
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusNoContent)
+		case "/hostgroup/api/nodes/api-2/persistent":
+			http.Error(w, "VM is being deleted", http.StatusConflict)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	for _, want := range []bool{true, false} {
+		if err := client.SetVMPersistent(ctx, "api", "api-1", want); err != nil {
+			t.Fatalf("SetVMPersistent(%v) error = %v", want, err)
+		}
+		if v, ok := got["persistent"]; !ok || v != want {
+			t.Fatalf("Want persistent=%v sent, got %v", want, got)
+		}
+	}
+
+	if err := client.SetVMPersistent(ctx, "api", "api-2", true); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Want ErrConflict, got %v", err)
+	}
+	if err := client.SetVMPersistent(ctx, "api", "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Want ErrNotFound, got %v", err)
+	}
+}
+
+func TestCreateVM_ValidatesIP(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"hostname":"vm-1"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	for _, ip := range []string{"192.168.137.300", "192.168.137.2/33", "10.0.0.2/", "vm-1"} {
+		if _, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{IP: ip}); err == nil || !strings.Contains(err.Error(), "invalid IP") {
+			t.Fatalf("CreateVM(IP=%q): want invalid IP error, got %v", ip, err)
+		}
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("Want invalid IPs rejected before any request, got %d", n)
+	}
+
+	for _, ip := range []string{"192.168.137.2", "192.168.137.2/24", "fd00::2/64"} {
+		if _, err := client.CreateVM(ctx, "vm", SlicerCreateNodeRequest{IP: ip}); err != nil {
+			t.Fatalf("CreateVM(IP=%q) error = %v", ip, err)
+		}
+	}
+}
+
+func TestParseArch(t *testing.T) {
+	tests := map[string]Arch{
+		"amd64":    ArchAMD64,
+		"x86_64":   ArchAMD64,
+		" X86-64 ": ArchAMD64,
+		"aarch64":  ArchARM64,
+		"ARM64":    ArchARM64,
+		"riscv64":  "riscv64",
+	}
+	for in, want := range tests {
+		if got := ParseArch(in); got != want {
+			t.Errorf("ParseArch(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// Reported values are kept as sent; only ParseArch maps them.
+	var node SlicerNode
+	if err := json.Unmarshal([]byte(`{"hostname":"vm-1","arch":"aarch64"}`), &node); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if node.Arch != "aarch64" || ParseArch(node.Arch) != ArchARM64 {
+		t.Fatalf("Want arch aarch64 normalizing to arm64, got %q", node.Arch)
+	}
+}
+
+func TestCreateVM_HostNode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got SlicerCreateNodeRequest
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if r.URL.Path != "/hostgroup/gpu/nodes" {
+			http.Error(w, "host group not found", http.StatusNotFound)
+			return
+		}
+		switch got.HostNode {
+		case "gpu-1":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"hostname":"vm-1"}`)
+		case "gpu-2":
+			http.Error(w, "insufficient capacity", http.StatusConflict)
+		default:
+			http.Error(w, "unknown host "+got.HostNode, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx := context.Background()
+
+	if _, err := client.CreateVM(ctx, "gpu", SlicerCreateNodeRequest{HostNode: "gpu-1"}); err != nil {
+		t.Fatalf("CreateVM() error = %v", err)
+	}
+
+	_, err := client.CreateVM(ctx, "gpu", SlicerCreateNodeRequest{HostNode: "gpu-2"})
+	if err == nil || !strings.Contains(err.Error(), `host node "gpu-2" cannot place the VM`) {
+		t.Fatalf("Want a full host error, got %v", err)
+	}
+
+	_, err = client.CreateVM(ctx, "gpu", SlicerCreateNodeRequest{HostNode: "gpu-9"})
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), `host node "gpu-9" is not known`) {
+		t.Fatalf("Want an unknown host error wrapping ErrNotFound, got %v", err)
+	}
+
+	_, err = client.CreateVM(ctx, "gpus", SlicerCreateNodeRequest{HostNode: "gpu-1"})
+	if !errors.Is(err, ErrNotFound) || strings.Contains(err.Error(), "is not known") || !strings.Contains(err.Error(), `host group "gpus" or host node "gpu-1"`) {
+		t.Fatalf("Want a missing host group not blamed on the host node, got %v", err)
+	}
+}
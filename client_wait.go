@@ -0,0 +1,118 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WaitForGroupReadyOptions controls WaitForGroupReadyWithOptions.
+type WaitForGroupReadyOptions struct {
+	// Interval is the time between polls. Zero means one second.
+	Interval time.Duration
+
+	// OnProgress, if set, is called after every poll with the number of
+	// healthy VMs and the total, e.g. for a CLI to show "7/10 ready".
+	OnProgress func(ready, total int)
+}
+
+// GroupNotReadyError is returned by WaitForGroupReady when ctx ends before
+// every VM in the group is healthy. It unwraps to the context's error.
+type GroupNotReadyError struct {
+	Group string
+	// NotReady lists the hostnames that never answered a health check.
+	NotReady []string
+	// LastErrors holds the most recent health check error per hostname.
+	LastErrors map[string]error
+	Err        error
+}
+
+func (e *GroupNotReadyError) Error() string {
+	return fmt.Sprintf("slicer: host group %s not ready, waiting on %s: %v", e.Group, strings.Join(e.NotReady, ", "), e.Err)
+}
+
+func (e *GroupNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForGroupReady blocks until every VM in the host group answers
+// GetAgentHealth, polling every interval, e.g. before running a test suite
+// against the group. The group's VMs are listed once, when the call starts.
+//
+// If ctx ends first, a *GroupNotReadyError naming the VMs that are not yet
+// healthy is returned.
+func (c *SlicerClient) WaitForGroupReady(ctx context.Context, groupName string, interval time.Duration) error {
+	return c.WaitForGroupReadyWithOptions(ctx, groupName, WaitForGroupReadyOptions{Interval: interval})
+}
+
+// WaitForGroupReadyWithOptions is like WaitForGroupReady but takes a
+// WaitForGroupReadyOptions, e.g. to report progress with OnProgress.
+func (c *SlicerClient) WaitForGroupReadyWithOptions(ctx context.Context, groupName string, options WaitForGroupReadyOptions) error {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	nodes, err := c.GetHostGroupNodes(ctx, groupName)
+	if err != nil {
+		return fmt.Errorf("slicer: WaitForGroupReady: %w", err)
+	}
+
+	pending := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		pending = append(pending, n.Hostname)
+	}
+	total := len(pending)
+	lastErrs := make(map[string]error)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		var (
+			mu       sync.Mutex
+			wg       sync.WaitGroup
+			notReady []string
+		)
+		for _, hostname := range pending {
+			wg.Add(1)
+			go func(hostname string) {
+				defer wg.Done()
+				_, err := c.GetAgentHealth(ctx, hostname, false)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					lastErrs[hostname] = err
+					notReady = append(notReady, hostname)
+					return
+				}
+				delete(lastErrs, hostname)
+			}(hostname)
+		}
+		wg.Wait()
+
+		slices.Sort(notReady)
+		pending = notReady
+		if options.OnProgress != nil {
+			options.OnProgress(total-len(pending), total)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return &GroupNotReadyError{
+				Group:      groupName,
+				NotReady:   pending,
+				LastErrors: lastErrs,
+				Err:        ctx.Err(),
+			}
+		case <-t.C:
+		}
+	}
+}
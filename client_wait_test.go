@@ -0,0 +1,57 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForGroupReady(t *testing.T) {
+	var vm2Polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hostgroup/web/nodes":
+			json.NewEncoder(w).Encode([]SlicerNode{{Hostname: "vm-1"}, {Hostname: "vm-2"}, {Hostname: "vm-3"}})
+		case "/vm/vm-1/health":
+			json.NewEncoder(w).Encode(SlicerAgentHealthResponse{Hostname: "vm-1"})
+		case "/vm/vm-2/health":
+			if vm2Polls.Add(1) < 3 {
+				http.Error(w, "agent unreachable", http.StatusBadGateway)
+				return
+			}
+			json.NewEncoder(w).Encode(SlicerAgentHealthResponse{Hostname: "vm-2"})
+		case "/vm/vm-3/health":
+			http.Error(w, "agent unreachable", http.StatusBadGateway)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var progress []int
+	err := client.WaitForGroupReadyWithOptions(ctx, "web", WaitForGroupReadyOptions{
+		Interval:   10 * time.Millisecond,
+		OnProgress: func(ready, total int) { progress = append(progress, ready) },
+	})
+
+	var notReady *GroupNotReadyError
+	if !errors.As(err, &notReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Want GroupNotReadyError wrapping the deadline, got %v", err)
+	}
+	if !slices.Equal(notReady.NotReady, []string{"vm-3"}) || notReady.LastErrors["vm-3"] == nil {
+		t.Fatalf("Want vm-3 not ready with its last error, got %+v", notReady)
+	}
+	if len(progress) < 3 || progress[0] != 1 || progress[len(progress)-1] != 2 {
+		t.Fatalf("Want progress from 1 to 2 ready, got %v", progress)
+	}
+}
@@ -0,0 +1,89 @@
+package slicer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ErrDryRun is returned, wrapped, by methods whose request was not sent
+// because the client was created with WithDryRun.
+var ErrDryRun = errors.New("dry run: request not sent")
+
+// maxDryRunBody caps how much of a request body is captured for a dry run,
+// so previewing a large upload does not buffer it in memory.
+const maxDryRunBody = 64 * 1024
+
+// RequestInfo describes a request intercepted by WithDryRun.
+type RequestInfo struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	// Body holds up to the first 64 KiB of the request body.
+	Body []byte
+	// Truncated is true when the body was longer than Body.
+	Truncated bool
+}
+
+// WithDryRun stops the client from sending mutating requests such as
+// CreateVM, DeleteVM or CreateSecret. Each one is passed to fn instead and
+// the method returns an error wrapping ErrDryRun. GET, HEAD and OPTIONS
+// requests are still sent, so read-only methods work as usual:
+//
+//	client := slicer.NewSlicerClient(url, token, ua, nil, slicer.WithDryRun(func(r slicer.RequestInfo) {
+//		fmt.Printf("would %s %s\n", r.Method, r.URL)
+//	}))
+//
+// The option applies regardless of its position relative to
+// WithRoundTripper.
+func WithDryRun(fn func(RequestInfo)) ClientOption {
+	return func(c *SlicerClient) {
+		c.dryRun = fn
+	}
+}
+
+// dryRunTransport answers mutating requests with ErrDryRun and forwards
+// everything else to next.
+type dryRunTransport struct {
+	next http.RoundTripper
+	fn   func(RequestInfo)
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	}
+
+	info := RequestInfo{
+		Method: req.Method,
+		URL:    req.URL,
+		Header: req.Header.Clone(),
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxDryRunBody+1))
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxDryRunBody {
+			body = body[:maxDryRunBody]
+			info.Truncated = true
+		}
+		info.Body = body
+	}
+
+	if t.fn != nil {
+		t.fn(info)
+	}
+	return nil, ErrDryRun
+}
+
+// CloseIdleConnections forwards to the wrapped transport so Close keeps
+// working for clients that own their transport.
+func (t *dryRunTransport) CloseIdleConnections() {
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
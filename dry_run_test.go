@@ -0,0 +1,55 @@
+package slicer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithDryRun_InterceptsMutatingRequests(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]SlicerHostGroup{{Name: "vm"}})
+	}))
+	defer server.Close()
+
+	var previewed []RequestInfo
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil, WithDryRun(func(r RequestInfo) {
+		previewed = append(previewed, r)
+	}))
+	ctx := context.Background()
+
+	if _, err := client.GetHostGroups(ctx); err != nil {
+		t.Fatalf("GetHostGroups: %v", err)
+	}
+
+	err := client.CreateSecret(ctx, CreateSecretRequest{Name: "db", Data: "c2VjcmV0"})
+	if !errors.Is(err, ErrDryRun) {
+		t.Fatalf("Want ErrDryRun from CreateSecret, got %v", err)
+	}
+	if _, err := client.DeleteVM(ctx, "vm", "vm-1"); !errors.Is(err, ErrDryRun) {
+		t.Fatalf("Want ErrDryRun from DeleteVM, got %v", err)
+	}
+
+	if len(sent) != 1 || sent[0] != "GET /hostgroup" {
+		t.Fatalf("Want only the GET sent, got %v", sent)
+	}
+	if len(previewed) != 2 {
+		t.Fatalf("Want 2 previewed requests, got %d", len(previewed))
+	}
+	if previewed[0].Method != http.MethodPost || previewed[0].URL.Path != "/secrets" {
+		t.Fatalf("Unexpected preview: %s %s", previewed[0].Method, previewed[0].URL)
+	}
+	var body CreateSecretRequest
+	if err := json.Unmarshal(previewed[0].Body, &body); err != nil || body.Name != "db" {
+		t.Fatalf("Want secret body in preview, got %q (%v)", previewed[0].Body, err)
+	}
+	if previewed[1].Method != http.MethodDelete || previewed[1].URL.Path != "/hostgroup/vm/nodes/vm-1" {
+		t.Fatalf("Unexpected preview: %s %s", previewed[1].Method, previewed[1].URL)
+	}
+}
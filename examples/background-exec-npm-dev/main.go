@@ -123,7 +123,7 @@ func main() {
 	if !*keep {
 		defer func() {
 			log.Printf("deleting vm=%s", node.Hostname)
-			if err := client.DeleteNode(hostGroup, node.Hostname); err != nil {
+			if _, err := client.DeleteVM(context.Background(), hostGroup, node.Hostname); err != nil {
 				log.Printf("delete vm failed: %v", err)
 			}
 		}()
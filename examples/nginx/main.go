@@ -81,7 +81,7 @@ func run() error {
 
 	defer func() {
 		fmt.Printf("→ deleting VM %s…\n", node.Hostname)
-		if err := client.DeleteNode(hostGroup, node.Hostname); err != nil {
+		if _, err := client.DeleteVM(context.Background(), hostGroup, node.Hostname); err != nil {
 			fmt.Fprintf(os.Stderr, "  delete failed: %v\n", err)
 		}
 	}()
@@ -1,10 +1,8 @@
 package slicer
 
 import (
-	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -544,53 +542,34 @@ func (c *RemoteCmd) processResults() {
 }
 
 // ExecWithReader is like Exec but accepts a custom io.Reader for stdin
-// instead of using os.Stdin.
+// instead of using os.Stdin. Cancelling ctx delivers a final frame with any
+// output already read, as for Exec.
 func (c *SlicerClient) ExecWithReader(ctx context.Context, nodeName string, execReq SlicerExecRequest, stdin io.Reader) (chan SlicerExecWriteResult, error) {
-	resChan := make(chan SlicerExecWriteResult)
-
-	command := execReq.Command
-	args := execReq.Args
-	uid := execReq.UID
-	gid := execReq.GID
-	shell := execReq.Shell
-	hasStdin := execReq.Stdin
+	ctx, cancel := c.copyContext(ctx)
+	streaming := false
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
 
-	cwd := execReq.Cwd
+	resChan := make(chan SlicerExecWriteResult, c.execBufferSize)
 
-	q := url.Values{}
-	q.Set("cmd", command)
-	if err := setExecStdioQuery(q, execReq); err != nil {
+	q, err := execQuery(execReq)
+	if err != nil {
 		return resChan, err
 	}
-
-	for _, arg := range args {
-		q.Add("args", arg)
-	}
-
-	for _, env := range execReq.Env {
-		q.Add("env", env)
-	}
-
-	q.Set("uid", strconv.FormatUint(uint64(uid), 10))
-	q.Set("gid", strconv.FormatUint(uint64(gid), 10))
-
-	if len(cwd) > 0 {
-		q.Set("cwd", cwd)
-	}
-
-	if len(execReq.Permissions) > 0 {
-		q.Set("permissions", execReq.Permissions)
-	}
+	// Unlike Exec, ExecWithReader has always sent uid and gid, so a
+	// NonRootUser value is passed on rather than left to the agent.
+	q.Set("uid", strconv.FormatUint(uint64(execReq.UID), 10))
+	q.Set("gid", strconv.FormatUint(uint64(execReq.GID), 10))
 
 	var bodyReader io.Reader
 
-	if hasStdin && stdin != nil {
+	if execReq.Stdin && stdin != nil {
 		q.Set("stdin", "true")
 		bodyReader = stdin
 	}
-	if len(shell) > 0 {
-		q.Set("shell", shell)
-	}
 
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
@@ -607,7 +586,7 @@ func (c *SlicerClient) ExecWithReader(ctx context.Context, nodeName string, exec
 
 	req.URL.RawQuery = q.Encode()
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return resChan, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -621,65 +600,50 @@ func (c *SlicerClient) ExecWithReader(ctx context.Context, nodeName string, exec
 			}()
 			body, _ = io.ReadAll(res.Body)
 		}
-		return resChan, fmt.Errorf("failed to execute command: %s %s", res.Status, string(body))
+		return resChan, fmt.Errorf("failed to execute command: %w", newAPIError(res, body))
 	}
 
 	if res.Body == nil {
 		return resChan, fmt.Errorf("no body received from VM")
 	}
 
+	streaming = true
 	go func() {
-		r := bufio.NewReader(res.Body)
+		frames := c.newExecFrameReader(res.Body, execReq)
 
+		defer cancel()
 		defer res.Body.Close()
 		defer close(resChan)
 
 		for {
 			select {
 			case <-ctx.Done():
+				sendFinal(resChan, frames.canceled(ctx.Err()))
 				return
 			default:
 			}
 
-			line, err := r.ReadBytes('\n')
+			result, err := frames.Next()
 			if err == io.EOF {
-				// Handle any remaining data before EOF
-				if len(line) > 0 {
-					var result SlicerExecWriteResult
-					if jsonErr := json.Unmarshal(line, &result); jsonErr == nil {
-						_ = decodeExecWriteResult(&result)
-						resChan <- result
-					}
-				}
 				break
 			}
-
-			if err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: time.Now(),
-					Error:     fmt.Sprintf("failed to read response: %v", err),
-				}
-				return
-			}
-
-			var result SlicerExecWriteResult
-			if err := json.Unmarshal(line, &result); err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: result.Timestamp,
-					Error:     fmt.Sprintf("failed to decode response: %v", err),
-				}
+			if err != nil && ctx.Err() != nil {
+				sendFinal(resChan, frames.canceled(ctx.Err()))
 				return
 			}
-			if err := decodeExecWriteResult(&result); err != nil {
-				resChan <- SlicerExecWriteResult{
-					Timestamp: result.Timestamp,
+			if err != nil {
+				frames.send(ctx, resChan, SlicerExecWriteResult{
+					Timestamp: time.Now(),
 					Error:     err.Error(),
-				}
+					readErr:   err,
+				})
 				return
 			}
 
 			// Send all results through the channel - let the caller handle exit codes
-			resChan <- result
+			if !frames.send(ctx, resChan, result) {
+				return
+			}
 
 			// If there's an error or non-zero exit code, this is the last message
 			if result.Error != "" || result.ExitCode != 0 {
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -56,6 +57,20 @@ type ExecBackgroundInfo struct {
 	RingBytes    int64      `json:"ring_bytes"`
 }
 
+// ExecStatus is the state of a background exec, as returned by
+// GetExecStatus.
+type ExecStatus struct {
+	ExecID    string
+	PID       int
+	Running   bool
+	StartedAt time.Time
+	// ExitCode is set once the process has exited normally.
+	ExitCode *int
+	// Signal names the signal that ended the process, if any.
+	Signal  string
+	EndedAt *time.Time
+}
+
 // LogOptions tunes GET /vm/{hostname}/exec/{exec_id}/logs.
 type LogOptions struct {
 	// Follow=true keeps the connection open and streams live frames after
@@ -147,7 +162,7 @@ func (c *SlicerClient) ExecBackground(ctx context.Context, vmName string, req Ex
 	}
 	c.setCommonHeaders(httpReq)
 
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecBackground: %w", err)
 	}
@@ -158,12 +173,39 @@ func (c *SlicerClient) ExecBackground(ctx context.Context, vmName string, req Ex
 	}
 
 	var out ExecBackgroundResponse
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecBackground: decode: %w", err)
 	}
 	return &out, nil
 }
 
+// ExecDetached starts execReq in the VM without waiting for it to finish,
+// for daemons and other long-running processes, and returns once it has
+// been launched. It is ExecBackground for callers that already build a
+// SlicerExecRequest: use the returned ExecID with ExecInfo, ExecLogs,
+// ExecKill and ExecDelete.
+//
+// Stdin, Extra, LoginShell, Permissions, MergeStderr and Stdio cannot be
+// honoured for a detached process and are rejected: the agent always
+// captures stdout and stderr separately into the exec's log ring.
+func (c *SlicerClient) ExecDetached(ctx context.Context, vmName string, execReq SlicerExecRequest) (*ExecBackgroundResponse, error) {
+	if execReq.Stdin || len(execReq.Extra) > 0 || execReq.LoginShell {
+		return nil, fmt.Errorf("slicer: ExecDetached: stdin, extra parameters and login shells are not supported for detached execs")
+	}
+	if execReq.Permissions != "" || execReq.MergeStderr || execReq.Stdio != "" {
+		return nil, fmt.Errorf("slicer: ExecDetached: permissions, merged stderr and stdio modes are not supported for detached execs")
+	}
+	return c.ExecBackground(ctx, vmName, ExecBackgroundRequest{
+		Command: execReq.Command,
+		Args:    execReq.Args,
+		Env:     execReq.Env,
+		UID:     execReq.UID,
+		GID:     execReq.GID,
+		Shell:   execReq.Shell,
+		Cwd:     execReq.Cwd,
+	})
+}
+
 // ExecList returns all background execs tracked by the VM's agent.
 func (c *SlicerClient) ExecList(ctx context.Context, vmName string) ([]ExecBackgroundInfo, error) {
 	u, err := c.vmURL(vmName, "exec", "")
@@ -175,7 +217,7 @@ func (c *SlicerClient) ExecList(ctx context.Context, vmName string) ([]ExecBackg
 		return nil, fmt.Errorf("slicer: ExecList: %w", err)
 	}
 	c.setCommonHeaders(httpReq)
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecList: %w", err)
 	}
@@ -184,7 +226,7 @@ func (c *SlicerClient) ExecList(ctx context.Context, vmName string) ([]ExecBackg
 		return nil, readAPIError(res, "ExecList")
 	}
 	var out []ExecBackgroundInfo
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecList: decode: %w", err)
 	}
 	return out, nil
@@ -201,7 +243,7 @@ func (c *SlicerClient) ExecInfo(ctx context.Context, vmName, execID string) (*Ex
 		return nil, fmt.Errorf("slicer: ExecInfo: %w", err)
 	}
 	c.setCommonHeaders(httpReq)
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecInfo: %w", err)
 	}
@@ -210,12 +252,50 @@ func (c *SlicerClient) ExecInfo(ctx context.Context, vmName, execID string) (*Ex
 		return nil, readAPIError(res, "ExecInfo")
 	}
 	var out ExecBackgroundInfo
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecInfo: decode: %w", err)
 	}
 	return &out, nil
 }
 
+// GetExecStatus reports whether a background exec, started with
+// ExecBackground or ExecDetached, is still running, along with its PID and
+// its exit code once finished. Poll it to track long-running processes.
+//
+// Returns an error wrapping ErrNotFound if the exec ID is unknown, or
+// ErrNotSupported if the agent does not track background execs. If
+// GetCapabilities has been called, servers without BackgroundExec are
+// refused without a round trip.
+func (c *SlicerClient) GetExecStatus(ctx context.Context, vmName, execID string) (ExecStatus, error) {
+	if caps, ok := c.cachedCapabilities(); ok && !caps.Inferred && !caps.BackgroundExec {
+		return ExecStatus{}, fmt.Errorf("slicer: GetExecStatus: server does not offer background exec: %w", ErrNotSupported)
+	}
+
+	info, err := c.ExecInfo(ctx, vmName, execID)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.StatusCode {
+			case http.StatusNotFound:
+				return ExecStatus{}, fmt.Errorf("slicer: GetExecStatus: %w: %w", apiErr, ErrNotFound)
+			case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+				return ExecStatus{}, fmt.Errorf("slicer: GetExecStatus: %w: %w", apiErr, ErrNotSupported)
+			}
+		}
+		return ExecStatus{}, err
+	}
+
+	return ExecStatus{
+		ExecID:    info.ExecID,
+		PID:       info.PID,
+		Running:   info.Running,
+		StartedAt: info.StartedAt,
+		ExitCode:  info.ExitCode,
+		Signal:    info.Signal,
+		EndedAt:   info.EndedAt,
+	}, nil
+}
+
 // ExecLogs opens the NDJSON log stream for a background exec.
 //
 // The returned channel is closed when the stream ends (follow=false: after all
@@ -241,7 +321,7 @@ func (c *SlicerClient) ExecLogs(ctx context.Context, vmName, execID string, opts
 	}
 	c.setCommonHeaders(httpReq)
 
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecLogs: %w", err)
 	}
@@ -300,7 +380,7 @@ func (c *SlicerClient) ExecKill(ctx context.Context, vmName, execID string, opts
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	c.setCommonHeaders(httpReq)
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecKill: %w", err)
 	}
@@ -309,7 +389,7 @@ func (c *SlicerClient) ExecKill(ctx context.Context, vmName, execID string, opts
 		return nil, readAPIError(res, "ExecKill")
 	}
 	var out ExecBackgroundKillResponse
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecKill: decode: %w", err)
 	}
 	return &out, nil
@@ -333,7 +413,7 @@ func (c *SlicerClient) ExecWaitExit(ctx context.Context, vmName, execID string,
 		return nil, fmt.Errorf("slicer: ExecWaitExit: %w", err)
 	}
 	c.setCommonHeaders(httpReq)
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecWaitExit: %w", err)
 	}
@@ -342,7 +422,7 @@ func (c *SlicerClient) ExecWaitExit(ctx context.Context, vmName, execID string,
 		return nil, readAPIError(res, "ExecWaitExit")
 	}
 	var out ExecBackgroundWaitExitResponse
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecWaitExit: decode: %w", err)
 	}
 	return &out, nil
@@ -360,7 +440,7 @@ func (c *SlicerClient) ExecDelete(ctx context.Context, vmName, execID string) (*
 		return nil, fmt.Errorf("slicer: ExecDelete: %w", err)
 	}
 	c.setCommonHeaders(httpReq)
-	res, err := c.httpClient.Do(httpReq)
+	res, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("slicer: ExecDelete: %w", err)
 	}
@@ -369,7 +449,7 @@ func (c *SlicerClient) ExecDelete(ctx context.Context, vmName, execID string) (*
 		return nil, readAPIError(res, "ExecDelete")
 	}
 	var out ExecBackgroundDeleteResponse
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+	if err := decodeJSONResponse(res, &out); err != nil {
 		return nil, fmt.Errorf("slicer: ExecDelete: decode: %w", err)
 	}
 	return &out, nil
@@ -392,7 +472,7 @@ func (c *SlicerClient) vmURL(vmName, root, sub string) (*url.URL, error) {
 
 func (c *SlicerClient) setCommonHeaders(req *http.Request) {
 	if c.userAgent != "" {
-		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("User-Agent", c.userAgentFor(req.Context()))
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
@@ -409,7 +489,7 @@ func drainClose(body io.ReadCloser) {
 
 func readAPIError(res *http.Response, op string) error {
 	body, _ := io.ReadAll(res.Body)
-	return fmt.Errorf("slicer: %s: %s - %s", op, res.Status, string(body))
+	return fmt.Errorf("slicer: %s: %w", op, newAPIError(res, body))
 }
 
 func newJSONReader(b []byte) io.Reader {
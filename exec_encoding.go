@@ -1,9 +1,14 @@
 package slicer
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"slices"
+	"time"
 )
 
 const (
@@ -20,6 +25,10 @@ func setExecStdioQuery(q url.Values, execReq SlicerExecRequest) error {
 		stdio = ExecStdioBase64
 	}
 
+	if execReq.MergeStderr {
+		q.Set("merge_stderr", "true")
+	}
+
 	switch stdio {
 	case ExecStdioText, ExecStdioBase64:
 		q.Set("stdio", stdio)
@@ -29,6 +38,41 @@ func setExecStdioQuery(q url.Values, execReq SlicerExecRequest) error {
 	}
 }
 
+// setExecShellQuery sets the shell and login parameters for execReq.
+func setExecShellQuery(q url.Values, execReq SlicerExecRequest) {
+	shell := execReq.Shell
+	if execReq.LoginShell {
+		if shell == "" {
+			shell = DefaultLoginShell
+		}
+		q.Set("login", "true")
+	}
+	if shell != "" {
+		q.Set("shell", shell)
+	}
+}
+
+// reservedExecParams are the exec query parameters the SDK sets itself,
+// which SlicerExecRequest.Extra may not override.
+var reservedExecParams = []string{
+	"cmd", "args", "env", "uid", "gid", "stdin", "stdout", "stderr",
+	"stdio", "shell", "login", "cwd", "permissions", "buffered", "merge_stderr",
+}
+
+// setExecExtraQuery adds execReq.Extra to q, refusing reserved keys.
+func setExecExtraQuery(q url.Values, execReq SlicerExecRequest) error {
+	for k, v := range execReq.Extra {
+		if k == "" {
+			return fmt.Errorf("exec extra parameter has an empty name")
+		}
+		if slices.Contains(reservedExecParams, k) {
+			return fmt.Errorf("exec extra parameter %q is reserved", k)
+		}
+		q.Set(k, v)
+	}
+	return nil
+}
+
 func decodeExecWriteResult(result *SlicerExecWriteResult) error {
 	if result.Encoding != ExecStdioBase64 {
 		return nil
@@ -123,3 +167,111 @@ func (r ExecResult) StderrBytes() ([]byte, error) {
 	}
 	return []byte(r.Stderr), nil
 }
+
+// execFrameReader decodes the successive JSON frames of an exec response.
+// Frames are split with a json.Decoder, so any whitespace between them is
+// accepted and a final frame without a trailing newline is not lost.
+type execFrameReader struct {
+	dec         *json.Decoder
+	unmarshal   func(data []byte, v any) error
+	mergeStderr bool
+}
+
+func (c *SlicerClient) newExecFrameReader(r io.Reader, execReq SlicerExecRequest) *execFrameReader {
+	unmarshal := c.execUnmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	return &execFrameReader{dec: json.NewDecoder(r), unmarshal: unmarshal, mergeStderr: execReq.MergeStderr}
+}
+
+// Next returns the next frame with its stdio decoded, or io.EOF once the
+// response ends cleanly.
+func (r *execFrameReader) Next() (SlicerExecWriteResult, error) {
+	var result SlicerExecWriteResult
+
+	var raw json.RawMessage
+	if err := r.dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return result, io.EOF
+		}
+		return result, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := r.unmarshal(raw, &result); err != nil {
+		return result, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := decodeExecWriteResult(&result); err != nil {
+		return result, err
+	}
+	if r.mergeStderr {
+		if result.Type == ExecStreamStderr {
+			result.Type = ExecStreamStdout
+		}
+		result.Stdout += result.Stderr
+		result.Stderr = ""
+	}
+	return result, nil
+}
+
+// execFinalFrameGrace bounds how long a cancelled exec stream waits for the
+// consumer to receive its final frame. A caller that stopped reading on
+// Ctrl-C would otherwise keep the goroutine and response body alive forever.
+const execFinalFrameGrace = time.Second
+
+// sendFinal delivers the last frame of a stream, giving up after
+// execFinalFrameGrace if nobody is receiving.
+func sendFinal(ch chan<- SlicerExecWriteResult, result SlicerExecWriteResult) {
+	t := time.NewTimer(execFinalFrameGrace)
+	defer t.Stop()
+	select {
+	case ch <- result:
+	case <-t.C:
+	}
+}
+
+// canceled returns the final frame delivered when the exec context ends the
+// stream early. It carries the output of pending, frames read but not yet
+// delivered, and of any complete frames that were already read into the
+// decoder's buffer, so the tail is not lost, and has Error set to ctxErr,
+// e.g. "context canceled".
+func (r *execFrameReader) canceled(ctxErr error, pending ...SlicerExecWriteResult) SlicerExecWriteResult {
+	final := SlicerExecWriteResult{Timestamp: time.Now(), Error: ctxErr.Error()}
+	for _, frame := range pending {
+		appendExecOutput(&final, frame)
+	}
+
+	rest := &execFrameReader{dec: json.NewDecoder(r.dec.Buffered()), unmarshal: r.unmarshal, mergeStderr: r.mergeStderr}
+	for {
+		frame, err := rest.Next()
+		if err != nil {
+			return final
+		}
+		appendExecOutput(&final, frame)
+	}
+}
+
+// appendExecOutput adds the stdout and stderr carried by frame to final.
+func appendExecOutput(final *SlicerExecWriteResult, frame SlicerExecWriteResult) {
+	switch frame.Type {
+	case ExecStreamStdout:
+		final.Stdout += frame.Stdout + frame.Data
+	case ExecStreamStderr:
+		final.Stderr += frame.Stderr + frame.Data
+	default:
+		final.Stdout += frame.Stdout
+		final.Stderr += frame.Stderr
+	}
+}
+
+// send delivers result on ch. If ctx ends while ch is full, the canceled
+// frame, carrying result's output, is offered instead with sendFinal and
+// false is returned so the caller stops reading.
+func (r *execFrameReader) send(ctx context.Context, ch chan<- SlicerExecWriteResult, result SlicerExecWriteResult) bool {
+	select {
+	case ch <- result:
+		return true
+	case <-ctx.Done():
+		sendFinal(ch, r.canceled(ctx.Err(), result))
+		return false
+	}
+}
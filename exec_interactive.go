@@ -0,0 +1,243 @@
+package slicer
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/slicervm/sdk/shell"
+)
+
+// shellHeartbeatInterval matches the interval used by browser terminals.
+const shellHeartbeatInterval = 30 * time.Second
+
+// ExecSession is an interactive PTY session opened by ExecInteractive.
+//
+// Output from the terminal is read from Stdout. A PTY merges the program's
+// stdout and stderr, so Stderr always reads as empty. Input is written to
+// Stdin; closing Stdin ends the session, since a terminal has no separate
+// end-of-input (write "\x04" to send EOF to the program instead).
+type ExecSession struct {
+	Stdin  io.WriteCloser
+	Stdout io.Reader
+	Stderr io.Reader
+
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	stdout *io.PipeReader
+
+	closeOnce sync.Once
+	errMu     sync.Mutex
+	err       error
+}
+
+// ExecInteractive opens an interactive terminal in the VM over the agent's
+// WebSocket shell endpoint, for building commands such as "slicer shell".
+//
+// The session runs execReq.Shell, or execReq.Command when Shell is empty,
+// falling back to the guest's default shell. UID, GID and Cwd are honoured;
+// Args and Env cannot be passed to the shell endpoint and are rejected.
+//
+// Returns an error wrapping ErrNotSupported if the server does not offer an
+// interactive shell, or ErrNotFound if the VM does not exist. If
+// GetCapabilities has been called, servers without PTYExec are refused
+// without a round trip.
+//
+// The session ends when the program exits, ctx is cancelled or Close is
+// called.
+func (c *SlicerClient) ExecInteractive(ctx context.Context, nodeName string, execReq SlicerExecRequest) (*ExecSession, error) {
+	if len(execReq.Args) > 0 || len(execReq.Env) > 0 {
+		return nil, fmt.Errorf("slicer: ExecInteractive: args and env are not supported for interactive sessions")
+	}
+	if caps, ok := c.cachedCapabilities(); ok && !caps.Inferred && !caps.PTYExec {
+		return nil, fmt.Errorf("slicer: ExecInteractive: server does not offer PTY exec: %w", ErrNotSupported)
+	}
+
+	q := url.Values{}
+	if sh := execReq.Shell; sh != "" {
+		q.Set("shell", sh)
+	} else if execReq.Command != "" {
+		q.Set("shell", execReq.Command)
+	}
+	if execReq.UID != 0 {
+		q.Set("uid", strconv.FormatUint(uint64(execReq.UID), 10))
+	}
+	if execReq.GID != 0 {
+		q.Set("gid", strconv.FormatUint(uint64(execReq.GID), 10))
+	}
+	if execReq.Cwd != "" {
+		q.Set("cwd", execReq.Cwd)
+	}
+
+	wsURL, err := c.websocketURL(fmt.Sprintf("/vm/%s/shell", nodeName), q)
+	if err != nil {
+		return nil, fmt.Errorf("slicer: ExecInteractive: %w", err)
+	}
+
+	conn, err := c.dialWebSocket(ctx, wsURL, "ExecInteractive")
+	if err != nil {
+		return nil, err
+	}
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	stdoutR, stdoutW := io.Pipe()
+
+	s := &ExecSession{
+		Stdout: stdoutR,
+		Stderr: strings.NewReader(""),
+		conn:   conn,
+		ctx:    sessionCtx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		stdout: stdoutR,
+	}
+	s.Stdin = &sessionStdin{s: s}
+
+	go s.readLoop(stdoutW)
+	go s.heartbeat()
+
+	return s, nil
+}
+
+// Resize sets the terminal size of the session.
+func (s *ExecSession) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("slicer: invalid terminal size %dx%d", cols, rows)
+	}
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint32(payload[0:4], uint32(cols))
+	binary.BigEndian.PutUint32(payload[4:8], uint32(rows))
+	return s.writeFrame(shell.FrameTypeWindowSize, payload)
+}
+
+// Wait blocks until the session ends and returns the error that ended it,
+// or nil if the program exited or the session was closed.
+func (s *ExecSession) Wait() error {
+	<-s.done
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.err
+}
+
+// Close ends the session, asking the agent to shut the terminal down. It
+// does not need Stdout to be drained; unread output is discarded.
+func (s *ExecSession) Close() error {
+	s.closeOnce.Do(func() {
+		_ = s.writeFrame(shell.FrameTypeShutdown, nil)
+		s.cancel()
+		// Fail any write readLoop is blocked in, so it can see the
+		// cancellation and finish.
+		s.stdout.CloseWithError(io.ErrClosedPipe)
+		_ = s.conn.Close(websocket.StatusNormalClosure, "session ended")
+	})
+	<-s.done
+	return nil
+}
+
+func (s *ExecSession) readLoop(stdout *io.PipeWriter) {
+	defer close(s.done)
+	defer s.conn.CloseNow()
+	defer s.cancel()
+
+	for {
+		typ, data, err := s.conn.Read(s.ctx)
+		if err != nil {
+			if s.ctx.Err() == nil && websocket.CloseStatus(err) != websocket.StatusNormalClosure {
+				s.setErr(err)
+			}
+			stdout.CloseWithError(io.EOF)
+			return
+		}
+		if typ != websocket.MessageBinary {
+			continue
+		}
+
+		frameType, payload, err := parseFrame(data)
+		if err != nil {
+			s.setErr(err)
+			stdout.CloseWithError(err)
+			return
+		}
+
+		switch frameType {
+		case shell.FrameTypeData:
+			if _, err := stdout.Write(payload); err != nil {
+				// Stdout was closed by Close; drop output until the
+				// read fails on the cancelled context.
+				continue
+			}
+		case shell.FrameTypeShutdown, shell.FrameTypeSessionClose:
+			stdout.CloseWithError(io.EOF)
+			return
+		}
+	}
+}
+
+func (s *ExecSession) heartbeat() {
+	t := time.NewTicker(shellHeartbeatInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+			if err := s.writeFrame(shell.FrameTypeHeartbeat, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *ExecSession) writeFrame(frameType byte, payload []byte) error {
+	return s.conn.Write(s.ctx, websocket.MessageBinary, encodeFrame(frameType, payload))
+}
+
+func (s *ExecSession) setErr(err error) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// sessionStdin sends writes to the terminal as data frames.
+type sessionStdin struct {
+	s *ExecSession
+}
+
+func (w *sessionStdin) Write(p []byte) (int, error) {
+	if err := w.s.writeFrame(shell.FrameTypeData, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *sessionStdin) Close() error {
+	return w.s.Close()
+}
+
+// websocketURL returns the ws:// or wss:// URL for an API path.
+func (c *SlicerClient) websocketURL(apiPath string, q url.Values) (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + apiPath
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,134 @@
+package slicer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/slicervm/sdk/shell"
+)
+
+func TestExecInteractive_EchoAndResize(t *testing.T) {
+	resized := make(chan [2]uint32, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vm/vm-1/shell" || r.URL.Query().Get("shell") != "/bin/bash" {
+			t.Errorf("Unexpected request %s", r.URL.String())
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		for {
+			_, data, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			frameType, payload, err := parseFrame(data)
+			if err != nil {
+				t.Errorf("parse frame: %v", err)
+				return
+			}
+			switch frameType {
+			case shell.FrameTypeWindowSize:
+				resized <- [2]uint32{binary.BigEndian.Uint32(payload[0:4]), binary.BigEndian.Uint32(payload[4:8])}
+			case shell.FrameTypeData:
+				_ = conn.Write(r.Context(), websocket.MessageBinary, data)
+				_ = conn.Write(r.Context(), websocket.MessageBinary, []byte{shell.FrameTypeSessionClose, 0, 0, 0, 0})
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	session, err := client.ExecInteractive(context.Background(), "vm-1", SlicerExecRequest{Shell: "/bin/bash"})
+	if err != nil {
+		t.Fatalf("ExecInteractive() error = %v", err)
+	}
+	defer session.Close()
+
+	if err := session.Resize(120, 40); err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+	if got := <-resized; got != [2]uint32{120, 40} {
+		t.Fatalf("Want size 120x40, got %dx%d", got[0], got[1])
+	}
+
+	if _, err := io.WriteString(session.Stdin, "hello"); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+	out, err := io.ReadAll(session.Stdout)
+	if err != nil {
+		t.Fatalf("read stdout: %v", err)
+	}
+	if string(out) != "hello" {
+		t.Fatalf("Want echoed output %q, got %q", "hello", out)
+	}
+	if err := session.Wait(); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
+
+func TestExecInteractive_NotSupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	_, err := client.ExecInteractive(context.Background(), "vm-1", SlicerExecRequest{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported, got %v", err)
+	}
+}
+
+func TestExecInteractive_CloseWithoutReading(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		// Write output nobody reads, then wait for the client to go away.
+		for i := 0; i < 3; i++ {
+			_ = conn.Write(r.Context(), websocket.MessageBinary, encodeFrame(shell.FrameTypeData, []byte("output")))
+		}
+		for {
+			if _, _, err := conn.Read(r.Context()); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	session, err := client.ExecInteractive(context.Background(), "vm-1", SlicerExecRequest{})
+	if err != nil {
+		t.Fatalf("ExecInteractive() error = %v", err)
+	}
+
+	// Give readLoop time to block writing the first frame to Stdout.
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		session.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked with unread output")
+	}
+}
@@ -0,0 +1,108 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Streams reported in ExecLine.Stream.
+const (
+	ExecStreamStdout = "stdout"
+	ExecStreamStderr = "stderr"
+	ExecStreamError  = "error"
+)
+
+// ExecLine is one line of command output, as returned by CollectExecLines.
+type ExecLine struct {
+	// Timestamp is the time of the frame in which the line started.
+	Timestamp time.Time `json:"timestamp"`
+	// Stream is ExecStreamStdout, ExecStreamStderr or ExecStreamError.
+	Stream string `json:"stream"`
+	// Text is the line without its trailing newline.
+	Text string `json:"text"`
+}
+
+// CollectExecLines drains an Exec result channel and returns its output
+// split into lines, with stdout and stderr kept apart and each line stamped
+// with the time of the frame it started in. Output that does not end in a
+// newline is returned as a final line.
+//
+// Error frames are kept in the returned lines with Stream set to
+// ExecStreamError, and the first of them is also returned as the error.
+// If ctx is cancelled the lines collected so far are returned with ctx.Err().
+func CollectExecLines(ctx context.Context, results <-chan SlicerExecWriteResult) ([]ExecLine, error) {
+	c := &execLineCollector{partial: map[string]*ExecLine{}}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return c.finish(), ctx.Err()
+		case result, ok := <-results:
+			if !ok {
+				return c.finish(), c.err
+			}
+			c.add(result)
+		}
+	}
+}
+
+// execLineCollector buffers partial lines per stream until a newline arrives.
+type execLineCollector struct {
+	lines   []ExecLine
+	partial map[string]*ExecLine
+	err     error
+}
+
+func (c *execLineCollector) add(result SlicerExecWriteResult) {
+	switch result.Type {
+	case "started", "exit":
+	case ExecStreamStdout:
+		c.write(result.Timestamp, ExecStreamStdout, result.Stdout+result.Data)
+	case ExecStreamStderr:
+		c.write(result.Timestamp, ExecStreamStderr, result.Stderr+result.Data)
+	default:
+		c.write(result.Timestamp, ExecStreamStdout, result.Stdout)
+		c.write(result.Timestamp, ExecStreamStderr, result.Stderr)
+	}
+
+	if result.Error != "" {
+		c.lines = append(c.lines, ExecLine{Timestamp: result.Timestamp, Stream: ExecStreamError, Text: result.Error})
+		if c.err == nil {
+			c.err = errors.New(result.Error)
+		}
+	}
+}
+
+func (c *execLineCollector) write(ts time.Time, stream, text string) {
+	for text != "" {
+		line, ok := c.partial[stream]
+		if !ok {
+			line = &ExecLine{Timestamp: ts, Stream: stream}
+			c.partial[stream] = line
+		}
+
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			line.Text += text
+			return
+		}
+
+		line.Text += text[:i]
+		c.lines = append(c.lines, *line)
+		delete(c.partial, stream)
+		text = text[i+1:]
+	}
+}
+
+// finish flushes any unterminated lines and returns the collected lines.
+func (c *execLineCollector) finish() []ExecLine {
+	for _, stream := range []string{ExecStreamStdout, ExecStreamStderr} {
+		if line, ok := c.partial[stream]; ok {
+			c.lines = append(c.lines, *line)
+			delete(c.partial, stream)
+		}
+	}
+	return c.lines
+}
@@ -0,0 +1,145 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ExecReconnectOptions controls ExecWithReconnect.
+type ExecReconnectOptions struct {
+	// MaxReconnects limits how many times the command is re-issued after
+	// the connection drops. Zero or less means no limit; the stream then
+	// ends only when the command does or ctx is cancelled.
+	MaxReconnects int
+	// Backoff is the wait before each reconnect, doubling after every
+	// failed attempt to re-issue the command. Zero defaults to one second.
+	Backoff time.Duration
+	// OnReconnect, if set, is called before the command is re-issued with
+	// the reconnect number, starting at one, and the error that ended the
+	// previous stream, so consumers know output may be missing or repeated.
+	OnReconnect func(attempt int, err error)
+}
+
+// ExecWithReconnect is like Exec, but when the connection is lost while
+// output is streaming, e.g. on a flaky link, it re-issues the command and
+// keeps delivering its results on the same channel instead of ending with
+// a read error. It is meant for idempotent or observational commands such
+// as tail -f or journalctl -f.
+//
+// A reconnect runs the command again from the start: the agent does not
+// resume where the stream dropped, so output produced while disconnected
+// is lost and output from the new run may repeat earlier lines. Only
+// connection failures trigger a reconnect; command errors, non-zero exit
+// codes and cancellation of ctx end the stream as for Exec, including the
+// grace period for a consumer that has stopped reading.
+//
+// execReq.Stdin cannot be replayed and is rejected.
+func (c *SlicerClient) ExecWithReconnect(ctx context.Context, nodeName string, execReq SlicerExecRequest, options ExecReconnectOptions) (<-chan SlicerExecWriteResult, error) {
+	if execReq.Stdin {
+		return nil, errors.New("slicer: ExecWithReconnect: stdin cannot be replayed on reconnect")
+	}
+
+	results, err := c.Exec(ctx, nodeName, execReq)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := options.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	out := make(chan SlicerExecWriteResult, c.execBufferSize)
+	go func() {
+		defer close(out)
+
+		attempt := 0
+		for {
+			var lost SlicerExecWriteResult
+			for result := range results {
+				if result.readErr != nil && isConnectionLoss(result.readErr) && ctx.Err() == nil {
+					lost = result
+					continue
+				}
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					// Nobody may be reading any more: pass on the cancel
+					// frame Exec ends with, but only for a grace period.
+					final := result
+					for r := range results {
+						final = r
+					}
+					sendFinal(out, final)
+					return
+				}
+			}
+			if lost.readErr == nil {
+				return
+			}
+
+			// Re-issue the command until it starts again, the limit is
+			// reached or ctx ends.
+			wait := backoff
+			for {
+				if options.MaxReconnects > 0 && attempt >= options.MaxReconnects {
+					lost.Error = fmt.Sprintf("%s (gave up after %d reconnects)", lost.Error, attempt)
+					sendFinal(out, lost)
+					return
+				}
+				attempt++
+
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					sendFinal(out, SlicerExecWriteResult{Timestamp: time.Now(), Error: ctx.Err().Error()})
+					return
+				case <-timer.C:
+				}
+
+				if options.OnReconnect != nil {
+					options.OnReconnect(attempt, lost.readErr)
+				}
+				results, err = c.Exec(ctx, nodeName, execReq)
+				if err == nil {
+					break
+				}
+				if !isReconnectable(err) {
+					sendFinal(out, SlicerExecWriteResult{Timestamp: time.Now(), Error: err.Error()})
+					return
+				}
+				lost.readErr = err
+				lost.Error = err.Error()
+				wait *= 2
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// isReconnectable reports whether err, from re-issuing the command, is
+// worth another attempt: the server could not be reached, or a proxy in
+// front of it is not ready yet.
+func isReconnectable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return IsRetryableError(err)
+}
+
+// isConnectionLoss reports whether err, from reading an exec response,
+// means the connection dropped rather than the response being malformed.
+func isConnectionLoss(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || IsRetryableError(err)
+}
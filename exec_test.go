@@ -5,10 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -519,3 +523,660 @@ func TestRemoteCmd_ShellPassedCorrectly(t *testing.T) {
 		t.Errorf("shell = %q, want /bin/bash", captured.QueryParams.Get("shell"))
 	}
 }
+
+func TestExecBuffered_PopulatesErrAndDuration(t *testing.T) {
+	started := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	server, captured := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ExecResult{
+			Stderr:    "nope\n",
+			StartedAt: started,
+			EndedAt:   started.Add(1500 * time.Millisecond),
+			ExitCode:  2,
+		})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	result, err := client.ExecBuffered(context.Background(), "test-vm", SlicerExecRequest{Command: "false", Stdio: ExecStdioText})
+	if err != nil {
+		t.Fatalf("ExecBuffered() error = %v", err)
+	}
+
+	if captured.QueryParams.Get("buffered") != "true" {
+		t.Errorf("Want buffered=true, got %q", captured.QueryParams.Get("buffered"))
+	}
+	if result.Duration != 1500*time.Millisecond {
+		t.Errorf("Want duration 1.5s, got %s", result.Duration)
+	}
+
+	var exitErr *ExitError
+	if !errors.As(result.Err, &exitErr) {
+		t.Fatalf("Want *ExitError, got %T: %v", result.Err, result.Err)
+	}
+	if exitErr.ExitCode() != 2 {
+		t.Errorf("Want exit code 2, got %d", exitErr.ExitCode())
+	}
+	if string(exitErr.Stderr) != "nope\n" {
+		t.Errorf("Want stderr %q, got %q", "nope\n", exitErr.Stderr)
+	}
+}
+
+func TestTranscriptExec_WritesLines(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeExecResult(w, SlicerExecWriteResult{Timestamp: ts, Type: "started", Pid: 42})
+		writeExecResult(w, SlicerExecWriteResult{Timestamp: ts, Type: "stdout", Data: "one\ntwo\n"})
+		writeExecResult(w, SlicerExecWriteResult{Timestamp: ts, Type: "exit"})
+	})
+
+	var transcript bytes.Buffer
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	resChan, err := client.TranscriptExec(context.Background(), "test-vm", SlicerExecRequest{Command: "echo", Stdio: ExecStdioText}, &transcript)
+	if err != nil {
+		t.Fatalf("TranscriptExec() error = %v", err)
+	}
+
+	var frames int
+	for range resChan {
+		frames++
+	}
+	if frames != 3 {
+		t.Fatalf("Want 3 frames on the channel, got %d", frames)
+	}
+
+	want := "2026-01-01T10:00:00Z started: pid=42\n" +
+		"2026-01-01T10:00:00Z stdout: one\n" +
+		"2026-01-01T10:00:00Z stdout: two\n" +
+		"2026-01-01T10:00:00Z exit: code=0\n"
+	if transcript.String() != want {
+		t.Fatalf("Want transcript:\n%s\ngot:\n%s", want, transcript.String())
+	}
+}
+
+func TestTranscriptExec_CancelDeliversFinalFrame(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStdout, Data: "line\n"})
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil, WithExecBufferSize(4))
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		resChan, err := client.TranscriptExec(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText}, io.Discard)
+		if err != nil {
+			t.Fatalf("TranscriptExec() error = %v", err)
+		}
+		if cap(resChan) != 4 {
+			t.Fatalf("Want the channel sized by WithExecBufferSize, got %d", cap(resChan))
+		}
+		<-resChan
+		cancel()
+
+		var last SlicerExecWriteResult
+		for res := range resChan {
+			last = res
+		}
+		if last.Error != context.Canceled.Error() {
+			t.Fatalf("Run %d: want a final frame with %q, got %+v", i, context.Canceled, last)
+		}
+	}
+}
+
+func TestCollectExecLines_SplitsStreams(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Second)
+
+	results := make(chan SlicerExecWriteResult, 4)
+	results <- SlicerExecWriteResult{Timestamp: t1, Type: "stdout", Data: "hello\nwor"}
+	results <- SlicerExecWriteResult{Timestamp: t2, Type: "stdout", Data: "ld\n"}
+	results <- SlicerExecWriteResult{Timestamp: t2, Type: "stderr", Data: "warn"}
+	results <- SlicerExecWriteResult{Timestamp: t2, Error: "failed to execute command: 1"}
+	close(results)
+
+	lines, err := CollectExecLines(context.Background(), results)
+	if err == nil || !strings.Contains(err.Error(), "failed to execute command") {
+		t.Fatalf("Want stream error, got %v", err)
+	}
+
+	want := []ExecLine{
+		{Timestamp: t1, Stream: ExecStreamStdout, Text: "hello"},
+		{Timestamp: t1, Stream: ExecStreamStdout, Text: "world"},
+		{Timestamp: t2, Stream: ExecStreamError, Text: "failed to execute command: 1"},
+		{Timestamp: t2, Stream: ExecStreamStderr, Text: "warn"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Want %d lines, got %d: %#v", len(want), len(lines), lines)
+	}
+	for i := range want {
+		if !lines[i].Timestamp.Equal(want[i].Timestamp) || lines[i].Stream != want[i].Stream || lines[i].Text != want[i].Text {
+			t.Errorf("line %d: want %#v, got %#v", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestExec_DecodesFramesWithoutTrailingNewline(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type":"stdout","data":"a"}  {"type":"stdout","data":"b"}` + "\n\n" + `{"type":"exit"}`))
+	})
+
+	var calls int
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil, WithExecUnmarshal(func(data []byte, v any) error {
+		calls++
+		return json.Unmarshal(data, v)
+	}))
+	resChan, err := client.Exec(context.Background(), "test-vm", SlicerExecRequest{Command: "echo", Stdio: ExecStdioText})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	var types, data []string
+	for res := range resChan {
+		if res.Error != "" {
+			t.Fatalf("Unexpected error frame: %s", res.Error)
+		}
+		types = append(types, res.Type)
+		data = append(data, res.Data)
+	}
+	if strings.Join(types, ",") != "stdout,stdout,exit" || strings.Join(data, "") != "ab" {
+		t.Fatalf("Want stdout a, stdout b, exit; got types %v data %v", types, data)
+	}
+	if calls != 3 {
+		t.Fatalf("Want custom unmarshal called for 3 frames, got %d", calls)
+	}
+}
+
+func TestExecWithReader_ReportsTruncatedFrame(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type":"stdout","data":"a"}` + "\n" + `{"type":"std`))
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	resChan, err := client.ExecWithReader(context.Background(), "test-vm", SlicerExecRequest{Command: "echo", Stdio: ExecStdioText}, nil)
+	if err != nil {
+		t.Fatalf("ExecWithReader() error = %v", err)
+	}
+
+	var last SlicerExecWriteResult
+	for res := range resChan {
+		last = res
+	}
+	if !strings.Contains(last.Error, "failed to read response") {
+		t.Fatalf("Want a read error for the truncated frame, got %+v", last)
+	}
+}
+
+func TestExec_MergeStderr(t *testing.T) {
+	server, captured := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeExecResult(w, SlicerExecWriteResult{Type: "stdout", Data: "one\n"})
+		writeExecResult(w, SlicerExecWriteResult{Type: "stderr", Data: "two\n"})
+		writeExecResult(w, SlicerExecWriteResult{Type: "stdout", Data: "three\n"})
+		writeExecResult(w, SlicerExecWriteResult{Type: "exit"})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	resChan, err := client.Exec(context.Background(), "test-vm", SlicerExecRequest{Command: "make", Stdio: ExecStdioText, MergeStderr: true})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	lines, err := CollectExecLines(context.Background(), resChan)
+	if err != nil {
+		t.Fatalf("CollectExecLines() error = %v", err)
+	}
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.Stream+":"+l.Text)
+	}
+	if strings.Join(got, ",") != "stdout:one,stdout:two,stdout:three" {
+		t.Fatalf("Want merged stdout in order, got %v", got)
+	}
+	if captured.QueryParams.Get("merge_stderr") != "true" {
+		t.Fatalf("Want merge_stderr=true, got %q", captured.QueryParams.Get("merge_stderr"))
+	}
+}
+
+func TestExec_CancelDeliversFinalFrame(t *testing.T) {
+	release := make(chan struct{})
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type":"stdout","data":"a"}` + "\n" + `{"type":"stdout","data":"b"}` + "\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	resChan, err := client.Exec(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	var output string
+	var last SlicerExecWriteResult
+	for res := range resChan {
+		output += res.Data + res.Stdout
+		last = res
+		if output == "a" {
+			cancel()
+		}
+	}
+
+	if output != "ab" {
+		t.Fatalf("Want all output read before cancel, got %q", output)
+	}
+	if last.Error != context.Canceled.Error() {
+		t.Fatalf("Want final frame with %q, got %+v", context.Canceled.Error(), last)
+	}
+}
+
+func TestExec_BufferedChannel(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, data := range []string{"a", "b", "c"} {
+			_, _ = w.Write([]byte(`{"type":"stdout","data":"` + data + `"}` + "\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil, WithExecBufferSize(2))
+	resChan, err := client.Exec(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	// The frames fill the buffer without being received.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(resChan) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Want 2 frames buffered, got %d", len(resChan))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The third frame is blocked on the full buffer; cancelling must
+	// release it into the final frame instead of dropping it.
+	cancel()
+
+	var output string
+	var last SlicerExecWriteResult
+	for res := range resChan {
+		output += res.Data + res.Stdout
+		last = res
+	}
+	if output != "abc" {
+		t.Fatalf("Want all output delivered, got %q", output)
+	}
+	if last.Error != context.Canceled.Error() {
+		t.Fatalf("Want final frame with %q, got %+v", context.Canceled.Error(), last)
+	}
+}
+
+func TestExecBuffered_ExtraQueryParams(t *testing.T) {
+	server, captured := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(SlicerExecWriteResult{Type: "exit"})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	_, err := client.ExecBuffered(context.Background(), "test-vm", SlicerExecRequest{
+		Command: "make",
+		Extra:   map[string]string{"nice": "10"},
+	})
+	if err != nil {
+		t.Fatalf("ExecBuffered() error = %v", err)
+	}
+	if got := captured.QueryParams.Get("nice"); got != "10" {
+		t.Fatalf("Want nice=10, got %q", got)
+	}
+
+	_, err = client.ExecBuffered(context.Background(), "test-vm", SlicerExecRequest{
+		Command: "make",
+		Extra:   map[string]string{"uid": "0"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `"uid" is reserved`) {
+		t.Fatalf("Want reserved key error, got %v", err)
+	}
+}
+
+func TestExecBuffered_LoginShell(t *testing.T) {
+	server, captured := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(SlicerExecWriteResult{Type: "exit"})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	if _, err := client.ExecBuffered(context.Background(), "test-vm", SlicerExecRequest{Command: "go version", LoginShell: true}); err != nil {
+		t.Fatalf("ExecBuffered() error = %v", err)
+	}
+	if captured.QueryParams.Get("login") != "true" || captured.QueryParams.Get("shell") != DefaultLoginShell {
+		t.Fatalf("Want login=true with the default shell, got %v", captured.QueryParams)
+	}
+
+	if _, err := client.ExecBuffered(context.Background(), "test-vm", SlicerExecRequest{Command: "go version", Shell: "/bin/bash", LoginShell: true}); err != nil {
+		t.Fatalf("ExecBuffered() error = %v", err)
+	}
+	if captured.QueryParams.Get("shell") != "/bin/bash" {
+		t.Fatalf("Want the requested shell kept, got %q", captured.QueryParams.Get("shell"))
+	}
+}
+
+func TestExecDetached_StartsBackgroundExec(t *testing.T) {
+	server, captured := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ExecBackgroundResponse{ExecID: "abc", PID: 42})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	res, err := client.ExecDetached(context.Background(), "test-vm", SlicerExecRequest{
+		Command: "redis-server",
+		Args:    []string{"--port", "6380"},
+		UID:     1000,
+	})
+	if err != nil {
+		t.Fatalf("ExecDetached() error = %v", err)
+	}
+	if res.ExecID != "abc" || res.PID != 42 {
+		t.Fatalf("Unexpected response %+v", res)
+	}
+	q := captured.QueryParams
+	if q.Get("background") != "true" || q.Get("cmd") != "redis-server" || q.Get("uid") != "1000" || len(q["args"]) != 2 {
+		t.Fatalf("Unexpected query %v", q)
+	}
+
+	if _, err := client.ExecDetached(context.Background(), "test-vm", SlicerExecRequest{Command: "cat", Stdin: true}); err == nil {
+		t.Fatal("Want error for stdin on a detached exec")
+	}
+
+	for name, req := range map[string]SlicerExecRequest{
+		"permissions":  {Command: "touch", Permissions: "0600"},
+		"merge_stderr": {Command: "make", MergeStderr: true},
+		"stdio":        {Command: "cat", Stdio: "base64"},
+	} {
+		if _, err := client.ExecDetached(context.Background(), "test-vm", req); err == nil {
+			t.Fatalf("Want error for %s on a detached exec", name)
+		}
+	}
+}
+
+func TestGetExecStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vm/test-vm/exec/abc":
+			_, _ = io.WriteString(w, `{"exec_id":"abc","pid":42,"running":false,"exit_code":3}`)
+		case "/capabilities":
+			_, _ = io.WriteString(w, `{"version":"0.1.0"}`)
+		default:
+			http.Error(w, "unknown exec", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	ctx := context.Background()
+
+	status, err := client.GetExecStatus(ctx, "test-vm", "abc")
+	if err != nil {
+		t.Fatalf("GetExecStatus() error = %v", err)
+	}
+	if status.Running || status.PID != 42 || status.ExitCode == nil || *status.ExitCode != 3 {
+		t.Fatalf("Unexpected status %+v", status)
+	}
+
+	_, err = client.GetExecStatus(ctx, "test-vm", "missing")
+	if !errors.Is(err, ErrNotFound) || !strings.HasPrefix(err.Error(), "slicer: GetExecStatus: 404 Not Found: unknown exec") {
+		t.Fatalf("Want ErrNotFound after the API error, got %v", err)
+	}
+
+	if _, err := client.GetCapabilities(ctx); err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	if _, err := client.GetExecStatus(ctx, "test-vm", "abc"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Want ErrNotSupported without BackgroundExec, got %v", err)
+	}
+}
+
+func TestExecWithReconnect(t *testing.T) {
+	var calls atomic.Int32
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Promise more than is sent, then drop the connection.
+			w.Header().Set("Content-Length", "1000")
+			writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStdout, Data: "line 1\n"})
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack() error = %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStdout, Data: "line 1\nline 2\n"})
+		writeExecResult(w, SlicerExecWriteResult{Type: "exit", ExitCode: 0})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+
+	var reconnects []int
+	results, err := client.ExecWithReconnect(context.Background(), "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText}, ExecReconnectOptions{
+		Backoff:     time.Millisecond,
+		OnReconnect: func(attempt int, err error) { reconnects = append(reconnects, attempt) },
+	})
+	if err != nil {
+		t.Fatalf("ExecWithReconnect() error = %v", err)
+	}
+
+	var output string
+	for res := range results {
+		if res.Error != "" {
+			t.Fatalf("Want no error frame, got %q", res.Error)
+		}
+		output += res.Data
+	}
+
+	// The command is replayed from the start after the drop.
+	if want := "line 1\nline 1\nline 2\n"; output != want {
+		t.Fatalf("Want output %q, got %q", want, output)
+	}
+	if !reflect.DeepEqual(reconnects, []int{1}) {
+		t.Fatalf("Want one reconnect reported, got %v", reconnects)
+	}
+
+	if _, err := client.ExecWithReconnect(context.Background(), "test-vm", SlicerExecRequest{Command: "cat", Stdin: true}, ExecReconnectOptions{}); err == nil {
+		t.Fatal("Want stdin rejected")
+	}
+}
+
+func TestExecWithReconnect_CancelWithoutReading(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		for {
+			writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStdout, Data: "line\n"})
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	results, err := client.ExecWithReconnect(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText}, ExecReconnectOptions{})
+	if err != nil {
+		t.Fatalf("ExecWithReconnect() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Once the grace period has passed the stream must have given up on
+	// the consumer and closed the channel, not be blocked on a send.
+	time.Sleep(execFinalFrameGrace + 500*time.Millisecond)
+	select {
+	case res, ok := <-results:
+		if ok {
+			t.Fatalf("Want the channel closed after the grace period, got frame %+v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel was not closed after cancel")
+	}
+}
+
+func TestExecToWriters(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeExecResult(w, SlicerExecWriteResult{Type: "started", Pid: 42})
+		writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStdout, Data: "out\n"})
+		writeExecResult(w, SlicerExecWriteResult{Type: ExecStreamStderr, Data: "err\n"})
+		code := 0
+		if r.URL.Query().Get("cmd") == "false" {
+			code = 3
+		}
+		writeExecResult(w, SlicerExecWriteResult{Type: "exit", ExitCode: code})
+	})
+
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", nil)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	code, err := client.ExecToWriters(ctx, "test-vm", SlicerExecRequest{Command: "true", Stdio: ExecStdioText}, &stdout, &stderr)
+	if err != nil || code != 0 {
+		t.Fatalf("ExecToWriters() = %d, %v, want 0, nil", code, err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Fatalf("Want stdout %q and stderr %q, got %q and %q", "out\n", "err\n", stdout.String(), stderr.String())
+	}
+
+	code, err = client.ExecToWriters(ctx, "test-vm", SlicerExecRequest{Command: "false", Stdio: ExecStdioText}, nil, nil)
+	var exitErr *ExitError
+	if code != 3 || !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || exitErr.Pid() != 42 {
+		t.Fatalf("Want exit code 3 with an ExitError for pid 42, got %d, %v", code, err)
+	}
+}
+
+func TestExec_CancelWithoutReadingReleasesStream(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type":"stdout","data":"a"}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	bodyClosed := make(chan struct{})
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			res.Body = &closeNotifyBody{ReadCloser: res.Body, closed: bodyClosed}
+		}
+		return res, err
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", httpClient)
+	if _, err := client.Exec(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText}); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	cancel()
+
+	// Never read from the channel: the goroutine must still give up on
+	// the final frame and close the response body.
+	select {
+	case <-bodyClosed:
+	case <-time.After(execFinalFrameGrace + 5*time.Second):
+		t.Fatal("response body was not closed after cancel")
+	}
+}
+
+func TestExec_BufferedCancelWithoutReadingReleasesStream(t *testing.T) {
+	server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, data := range []string{"a", "b", "c"} {
+			_, _ = w.Write([]byte(`{"type":"stdout","data":"` + data + `"}` + "\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	bodyClosed := make(chan struct{})
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			res.Body = &closeNotifyBody{ReadCloser: res.Body, closed: bodyClosed}
+		}
+		return res, err
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewSlicerClient(server.URL, "test-token", "test-agent", httpClient, WithExecBufferSize(1))
+	if _, err := client.Exec(ctx, "test-vm", SlicerExecRequest{Command: "tail", Stdio: ExecStdioText}); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	// Let the buffer fill so the reader blocks on the next frame.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-bodyClosed:
+	case <-time.After(execFinalFrameGrace + 5*time.Second):
+		t.Fatal("response body was not closed after cancel")
+	}
+}
+
+func TestExec_BufferedCancelWithUnreadExitFrameReleasesStream(t *testing.T) {
+	tests := map[string]func(c *SlicerClient, ctx context.Context) (chan SlicerExecWriteResult, error){
+		"Exec": func(c *SlicerClient, ctx context.Context) (chan SlicerExecWriteResult, error) {
+			return c.Exec(ctx, "test-vm", SlicerExecRequest{Command: "false", Stdio: ExecStdioText})
+		},
+		"ExecWithReader": func(c *SlicerClient, ctx context.Context) (chan SlicerExecWriteResult, error) {
+			return c.ExecWithReader(ctx, "test-vm", SlicerExecRequest{Command: "false", Stdio: ExecStdioText}, nil)
+		},
+	}
+
+	for name, exec := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"type":"stdout","data":"a"}` + "\n"))
+				_, _ = w.Write([]byte(`{"exit_code":1}` + "\n"))
+			})
+
+			bodyClosed := make(chan struct{})
+			httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				res, err := http.DefaultTransport.RoundTrip(req)
+				if err == nil {
+					res.Body = &closeNotifyBody{ReadCloser: res.Body, closed: bodyClosed}
+				}
+				return res, err
+			})}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			client := NewSlicerClient(server.URL, "test-token", "test-agent", httpClient, WithExecBufferSize(1))
+			if _, err := exec(client, ctx); err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+			// The first frame fills the buffer, so the exit frame cannot be sent.
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			select {
+			case <-bodyClosed:
+			case <-time.After(execFinalFrameGrace + 5*time.Second):
+				t.Fatal("response body was not closed after cancel")
+			}
+		})
+	}
+}
+
+// closeNotifyBody closes closed when the body is closed.
+type closeNotifyBody struct {
+	io.ReadCloser
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (b *closeNotifyBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return b.ReadCloser.Close()
+}
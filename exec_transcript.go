@@ -0,0 +1,106 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// TranscriptExec is like Exec but additionally writes a timestamped,
+// line-by-line transcript of the session to w as results stream in. Every
+// result is still delivered on the returned channel, which is sized with
+// WithExecBufferSize, and cancelling ctx ends the stream as for Exec.
+//
+// Each transcript line has the form "<RFC3339Nano timestamp> <stream>: <text>",
+// where stream is one of started, stdout, stderr, exit or error. If w has a
+// Flush method it is called after every line. A failed write stops the
+// transcript but does not interrupt the command.
+func (c *SlicerClient) TranscriptExec(ctx context.Context, nodeName string, execReq SlicerExecRequest, w io.Writer) (chan SlicerExecWriteResult, error) {
+	resChan, err := c.Exec(ctx, nodeName, execReq)
+	if err != nil {
+		return resChan, err
+	}
+
+	out := make(chan SlicerExecWriteResult, c.execBufferSize)
+	go func() {
+		defer close(out)
+
+		t := &transcriptWriter{w: w}
+		for result := range resChan {
+			t.write(result)
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				// Forward the cancel frame Exec ends with, giving a
+				// consumer that stopped reading only the grace period.
+				final := result
+				for r := range resChan {
+					t.write(r)
+					final = r
+				}
+				sendFinal(out, final)
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// transcriptWriter formats exec results as transcript lines.
+type transcriptWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *transcriptWriter) write(result SlicerExecWriteResult) {
+	ts := result.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	switch result.Type {
+	case "started":
+		t.line(ts, "started", fmt.Sprintf("pid=%d", result.Pid))
+		return
+	case "stdout":
+		t.text(ts, "stdout", result.Stdout+result.Data)
+	case "stderr":
+		t.text(ts, "stderr", result.Stderr+result.Data)
+	default:
+		t.text(ts, "stdout", result.Stdout)
+		t.text(ts, "stderr", result.Stderr)
+	}
+
+	if result.Error != "" {
+		t.line(ts, "error", result.Error)
+	}
+	if result.Type == "exit" {
+		t.line(ts, "exit", fmt.Sprintf("code=%d", result.ExitCode))
+	}
+}
+
+// text writes one transcript line per line of s.
+func (t *transcriptWriter) text(ts time.Time, stream, s string) {
+	if s == "" {
+		return
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		t.line(ts, stream, l)
+	}
+}
+
+func (t *transcriptWriter) line(ts time.Time, stream, text string) {
+	if t.err != nil {
+		return
+	}
+	if _, err := fmt.Fprintf(t.w, "%s %s: %s\n", ts.UTC().Format(time.RFC3339Nano), stream, text); err != nil {
+		t.err = err
+		return
+	}
+	if f, ok := t.w.(interface{ Flush() error }); ok {
+		t.err = f.Flush()
+	}
+}
@@ -0,0 +1,92 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExecToWriters runs a command like Exec and copies its output to stdout
+// and stderr as it streams in, like cmd.Stdout and cmd.Stderr in os/exec,
+// returning the exit code once the command ends. Either writer may be nil
+// to discard that stream; with execReq.MergeStderr everything goes to
+// stdout.
+//
+// A non-zero exit returns the code with an *ExitError. If ctx is cancelled,
+// the output read so far has been written and -1 is returned with
+// ctx.Err(). If a writer fails the command is stopped and the write error
+// is returned.
+func (c *SlicerClient) ExecToWriters(ctx context.Context, nodeName string, execReq SlicerExecRequest, stdout, stderr io.Writer) (int, error) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	execCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results, err := c.Exec(execCtx, nodeName, execReq)
+	if err != nil {
+		return -1, err
+	}
+
+	var writeErr, execErr error
+	exitCode, pid := 0, 0
+	for result := range results {
+		if writeErr == nil {
+			if writeErr = writeExecOutput(stdout, stderr, result); writeErr != nil {
+				writeErr = fmt.Errorf("failed to write output: %w", writeErr)
+				cancel()
+			}
+		}
+
+		switch {
+		case result.Type == "started":
+			pid = result.Pid
+		case result.ExitCode != 0:
+			exitCode = result.ExitCode
+		case result.Error != "":
+			execErr = errors.New(result.Error)
+		}
+	}
+
+	switch {
+	case writeErr != nil:
+		return -1, writeErr
+	case ctx.Err() != nil:
+		return -1, ctx.Err()
+	case exitCode != 0:
+		return exitCode, &ExitError{RemoteProcessState: &RemoteProcessState{exitCode: exitCode, exited: true, pid: pid}}
+	case execErr != nil:
+		return -1, execErr
+	}
+	return 0, nil
+}
+
+// writeExecOutput writes the output carried by result to stdout and stderr.
+func writeExecOutput(stdout, stderr io.Writer, result SlicerExecWriteResult) error {
+	var out, errOut string
+	switch result.Type {
+	case ExecStreamStdout:
+		out = result.Stdout + result.Data
+	case ExecStreamStderr:
+		errOut = result.Stderr + result.Data
+	default:
+		out, errOut = result.Stdout, result.Stderr
+	}
+
+	if out != "" {
+		if _, err := io.WriteString(stdout, out); err != nil {
+			return err
+		}
+	}
+	if errOut != "" {
+		if _, err := io.WriteString(stderr, errOut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
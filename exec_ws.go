@@ -0,0 +1,313 @@
+package slicer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+// Frame types of the WebSocket exec protocol used by ExecWebSocket.
+//
+// Every WebSocket message is a single binary frame with a 5-byte header: one
+// byte frame type followed by the payload length as a big-endian uint32,
+// then the payload. This is the same framing as the interactive shell
+// protocol in package shell, with its own frame types:
+//
+//	0x11 stdin        client -> server  bytes for the process's stdin
+//	0x12 stdin-close  client -> server  no payload; closes the process's stdin
+//	0x13 stdout       server -> client  bytes written to stdout
+//	0x14 stderr       server -> client  bytes written to stderr
+//	0x15 exit         server -> client  exit code as a big-endian int32; last frame
+//	0x16 error        server -> client  UTF-8 error message; last frame
+//
+// The command is described by the same query parameters as the HTTP exec
+// endpoint, on GET /vm/{hostname}/exec/ws.
+const (
+	ExecFrameStdin      = 0x11
+	ExecFrameStdinClose = 0x12
+	ExecFrameStdout     = 0x13
+	ExecFrameStderr     = 0x14
+	ExecFrameExit       = 0x15
+	ExecFrameError      = 0x16
+)
+
+// ExecConn is a running command with live, bidirectional stdio, opened by
+// ExecWebSocket.
+//
+// Output is buffered in memory until it is read, so a caller may read only
+// Stdout, or neither stream, without stalling the command; output that is
+// never read is held until Close. Closing Stdin closes the process's stdin.
+type ExecConn struct {
+	Stdin  io.WriteCloser
+	Stdout io.Reader
+	Stderr io.Reader
+
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	stdout *outputBuffer
+	stderr *outputBuffer
+
+	mergeStderr bool
+
+	exitCode int
+	err      error
+}
+
+// ExecWebSocket runs a command in the VM over a WebSocket that multiplexes
+// stdin, stdout and stderr, so input can be fed while output streams back.
+// Use Exec for simple non-interactive commands.
+//
+// execReq.Stdin is ignored; input is written to ExecConn.Stdin instead.
+// Returns an error wrapping ErrNotSupported if the server does not offer
+// WebSocket exec. If GetCapabilities has been called, servers without
+// WebSocketExec are refused without a round trip.
+func (c *SlicerClient) ExecWebSocket(ctx context.Context, nodeName string, execReq SlicerExecRequest) (*ExecConn, error) {
+	if caps, ok := c.cachedCapabilities(); ok && !caps.Inferred && !caps.WebSocketExec {
+		return nil, fmt.Errorf("slicer: ExecWebSocket: server does not offer WebSocket exec: %w", ErrNotSupported)
+	}
+
+	q, err := execQuery(execReq)
+	if err != nil {
+		return nil, err
+	}
+
+	wsURL, err := c.websocketURL(fmt.Sprintf("/vm/%s/exec/ws", nodeName), q)
+	if err != nil {
+		return nil, fmt.Errorf("slicer: ExecWebSocket: %w", err)
+	}
+
+	conn, err := c.dialWebSocket(ctx, wsURL, "ExecWebSocket")
+	if err != nil {
+		return nil, err
+	}
+
+	connCtx, cancel := context.WithCancel(ctx)
+	stdout, stderr := newOutputBuffer(), newOutputBuffer()
+
+	e := &ExecConn{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		conn:     conn,
+		ctx:      connCtx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		stdout:   stdout,
+		stderr:   stderr,
+		exitCode: -1,
+
+		mergeStderr: execReq.MergeStderr,
+	}
+	e.Stdin = &execConnStdin{e: e}
+
+	go e.readLoop()
+
+	return e, nil
+}
+
+// Wait blocks until the command exits and returns its exit code. The error
+// is set if the server reported a failure or the connection was lost before
+// an exit frame arrived.
+func (e *ExecConn) Wait() (int, error) {
+	<-e.done
+	return e.exitCode, e.err
+}
+
+// Close terminates the connection without waiting for the command to exit,
+// and discards any output that has not been read.
+func (e *ExecConn) Close() error {
+	e.cancel()
+	e.stdout.close()
+	e.stderr.close()
+	<-e.done
+	return nil
+}
+
+func (e *ExecConn) readLoop() {
+	stdout, stderr := e.stdout, e.stderr
+	var finalErr error
+	defer func() {
+		stdout.closeWrite()
+		stderr.closeWrite()
+		e.err = finalErr
+		e.cancel()
+		e.conn.CloseNow()
+		close(e.done)
+	}()
+
+	for {
+		typ, data, err := e.conn.Read(e.ctx)
+		if err != nil {
+			finalErr = fmt.Errorf("slicer: exec connection closed before exit: %w", err)
+			return
+		}
+		if typ != websocket.MessageBinary {
+			continue
+		}
+
+		frameType, payload, err := parseFrame(data)
+		if err != nil {
+			finalErr = err
+			return
+		}
+
+		switch frameType {
+		case ExecFrameStdout:
+			stdout.write(payload)
+		case ExecFrameStderr:
+			if e.mergeStderr {
+				stdout.write(payload)
+			} else {
+				stderr.write(payload)
+			}
+		case ExecFrameExit:
+			if len(payload) != 4 {
+				finalErr = errors.New("slicer: malformed exec exit frame")
+				return
+			}
+			e.exitCode = int(int32(binary.BigEndian.Uint32(payload)))
+			return
+		case ExecFrameError:
+			finalErr = fmt.Errorf("command failed: %s", payload)
+			return
+		}
+	}
+}
+
+// outputBuffer is an in-memory pipe whose writes never block, so one
+// unread stream cannot hold up the frames of the other.
+type outputBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	eof    bool
+	closed bool
+}
+
+func newOutputBuffer() *outputBuffer {
+	b := &outputBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Read blocks until output is available, returning io.EOF once the
+// command has finished and everything has been read.
+func (b *outputBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() == 0 && !b.eof && !b.closed {
+		b.cond.Wait()
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if b.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return b.buf.Read(p)
+}
+
+func (b *outputBuffer) write(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.buf.Write(p)
+		b.cond.Broadcast()
+	}
+}
+
+// closeWrite marks the end of output.
+func (b *outputBuffer) closeWrite() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.eof = true
+	b.cond.Broadcast()
+}
+
+// close discards buffered output and fails pending and future reads.
+func (b *outputBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.buf.Reset()
+	b.cond.Broadcast()
+}
+
+// execConnStdin sends writes to the process's stdin as frames.
+type execConnStdin struct {
+	e    *ExecConn
+	once sync.Once
+}
+
+func (w *execConnStdin) Write(p []byte) (int, error) {
+	if err := w.e.conn.Write(w.e.ctx, websocket.MessageBinary, encodeFrame(ExecFrameStdin, p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *execConnStdin) Close() error {
+	var err error
+	w.once.Do(func() {
+		err = w.e.conn.Write(w.e.ctx, websocket.MessageBinary, encodeFrame(ExecFrameStdinClose, nil))
+	})
+	return err
+}
+
+// dialWebSocket opens a WebSocket to the API with the client's transport and
+// credentials, mapping upgrade refusals onto ErrNotSupported and ErrNotFound.
+func (c *SlicerClient) dialWebSocket(ctx context.Context, wsURL, op string) (*websocket.Conn, error) {
+	h := http.Header{}
+	if c.userAgent != "" {
+		h.Set("User-Agent", c.userAgentFor(ctx))
+	}
+	if c.token != "" {
+		h.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, res, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{
+		HTTPClient: c.httpClient,
+		HTTPHeader: h,
+	})
+	if err != nil {
+		if res != nil {
+			switch res.StatusCode {
+			case http.StatusNotImplemented, http.StatusMethodNotAllowed, http.StatusUpgradeRequired:
+				return nil, fmt.Errorf("slicer: %s: %s: %w", op, res.Status, ErrNotSupported)
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("slicer: %s: %s: %w", op, res.Status, ErrNotFound)
+			}
+		}
+		return nil, fmt.Errorf("slicer: %s: %w", op, err)
+	}
+	return conn, nil
+}
+
+// encodeFrame builds a frame with the 5-byte type and length header.
+func encodeFrame(frameType byte, payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+// parseFrame splits a framed message into its type and payload.
+func parseFrame(data []byte) (byte, []byte, error) {
+	if len(data) < 5 {
+		return 0, nil, errors.New("slicer: short frame")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if uint64(n) > uint64(len(data)-5) {
+		return 0, nil, errors.New("slicer: truncated frame")
+	}
+	return data[0], data[5 : 5+n], nil
+}
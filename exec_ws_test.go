@@ -0,0 +1,164 @@
+package slicer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestExecWebSocket_StreamsStdio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vm/vm-1/exec/ws" || r.URL.Query().Get("cmd") != "cat" {
+			t.Errorf("Unexpected request %s", r.URL.String())
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		for {
+			_, data, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			frameType, payload, _ := parseFrame(data)
+			switch frameType {
+			case ExecFrameStdin:
+				_ = conn.Write(r.Context(), websocket.MessageBinary, encodeFrame(ExecFrameStdout, payload))
+				_ = conn.Write(r.Context(), websocket.MessageBinary, encodeFrame(ExecFrameStderr, []byte("warn")))
+			case ExecFrameStdinClose:
+				_ = conn.Write(r.Context(), websocket.MessageBinary, encodeFrame(ExecFrameExit, []byte{0, 0, 0, 3}))
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	execConn, err := client.ExecWebSocket(context.Background(), "vm-1", SlicerExecRequest{Command: "cat", UID: NonRootUser, GID: NonRootUser})
+	if err != nil {
+		t.Fatalf("ExecWebSocket() error = %v", err)
+	}
+	defer execConn.Close()
+
+	stderr := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(execConn.Stderr)
+		stderr <- string(b)
+	}()
+
+	if _, err := io.WriteString(execConn.Stdin, "ping"); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(execConn.Stdout, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Want stdout %q, got %q (err %v)", "ping", buf, err)
+	}
+	if err := execConn.Stdin.Close(); err != nil {
+		t.Fatalf("close stdin: %v", err)
+	}
+
+	code, err := execConn.Wait()
+	if err != nil || code != 3 {
+		t.Fatalf("Want exit code 3, got %d (err %v)", code, err)
+	}
+	if got := <-stderr; got != "warn" {
+		t.Fatalf("Want stderr %q, got %q", "warn", got)
+	}
+}
+
+// execWSServer runs a WebSocket exec endpoint that sends frames and then
+// waits for the client to disconnect.
+func execWSServer(t *testing.T, frames ...[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		for _, frame := range frames {
+			_ = conn.Write(r.Context(), websocket.MessageBinary, frame)
+		}
+		for {
+			if _, _, err := conn.Read(r.Context()); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestExecWebSocket_ReadOnlyStdout(t *testing.T) {
+	var frames [][]byte
+	for i := 0; i < 8; i++ {
+		frames = append(frames, encodeFrame(ExecFrameStderr, bytes.Repeat([]byte("e"), 16<<10)))
+	}
+	frames = append(frames,
+		encodeFrame(ExecFrameStdout, []byte("done")),
+		encodeFrame(ExecFrameExit, []byte{0, 0, 0, 0}),
+	)
+	server := execWSServer(t, frames...)
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	execConn, err := client.ExecWebSocket(context.Background(), "vm-1", SlicerExecRequest{Command: "build"})
+	if err != nil {
+		t.Fatalf("ExecWebSocket() error = %v", err)
+	}
+	defer execConn.Close()
+
+	out := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(execConn.Stdout)
+		out <- string(b)
+	}()
+	select {
+	case got := <-out:
+		if got != "done" {
+			t.Fatalf("Want stdout %q, got %q", "done", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stdout stalled behind unread stderr")
+	}
+	if code, err := execConn.Wait(); err != nil || code != 0 {
+		t.Fatalf("Want exit code 0, got %d (err %v)", code, err)
+	}
+}
+
+func TestExecWebSocket_CloseWithoutReading(t *testing.T) {
+	server := execWSServer(t,
+		encodeFrame(ExecFrameStdout, []byte("output")),
+		encodeFrame(ExecFrameStderr, []byte("warn")),
+	)
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	execConn, err := client.ExecWebSocket(context.Background(), "vm-1", SlicerExecRequest{Command: "serve"})
+	if err != nil {
+		t.Fatalf("ExecWebSocket() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		execConn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked with unread output")
+	}
+	if _, err := execConn.Stdout.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Fatalf("Want io.ErrClosedPipe reading after Close, got %v", err)
+	}
+}
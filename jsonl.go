@@ -0,0 +1,78 @@
+package slicer
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// LogStream is the stream reported for VM log lines by WriteLogsJSONL.
+const LogStream = "log"
+
+// JSONLRecord is one line written by WriteExecJSONL and WriteLogsJSONL.
+type JSONLRecord struct {
+	// Timestamp is when the line was produced, if known. VM logs carry no
+	// per-line timestamps, so it is omitted for them.
+	Timestamp time.Time `json:"timestamp,omitzero"`
+	// Stream is ExecStreamStdout, ExecStreamStderr, ExecStreamError or
+	// LogStream.
+	Stream string `json:"stream"`
+	// Text is the line without its trailing newline.
+	Text string `json:"text"`
+	// Node is the VM the line came from, when known.
+	Node string `json:"node,omitempty"`
+}
+
+// WriteExecJSONL drains an Exec result channel and writes its output to w as
+// JSON Lines, one JSONLRecord per line of output, as it arrives. Lines are
+// split and stamped as by CollectExecLines; Node is left empty.
+//
+// Error frames are written with Stream set to ExecStreamError, and the first
+// of them is returned as the error once the channel is drained. A write
+// error stops the export and is returned immediately, leaving the channel
+// undrained; cancel the Exec context to stop the command.
+func WriteExecJSONL(w io.Writer, results <-chan SlicerExecWriteResult) error {
+	enc := json.NewEncoder(w)
+	c := &execLineCollector{partial: map[string]*ExecLine{}}
+
+	flush := func() error {
+		for _, line := range c.lines {
+			if err := enc.Encode(JSONLRecord{Timestamp: line.Timestamp, Stream: line.Stream, Text: line.Text}); err != nil {
+				return err
+			}
+		}
+		c.lines = c.lines[:0]
+		return nil
+	}
+
+	for result := range results {
+		c.add(result)
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	c.finish()
+	if err := flush(); err != nil {
+		return err
+	}
+	return c.err
+}
+
+// WriteLogsJSONL writes the content of a GetVMLogs response to w as JSON
+// Lines, one JSONLRecord per log line with Stream set to LogStream and Node
+// set to the VM's hostname.
+func WriteLogsJSONL(w io.Writer, logs SlicerLogsResponse) error {
+	if logs.Content == "" {
+		return nil
+	}
+
+	enc := json.NewEncoder(w)
+	for _, line := range strings.Split(strings.TrimSuffix(logs.Content, "\n"), "\n") {
+		if err := enc.Encode(JSONLRecord{Stream: LogStream, Text: line, Node: logs.Hostname}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package slicer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteExecJSONL(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	results := make(chan SlicerExecWriteResult, 4)
+	results <- SlicerExecWriteResult{Timestamp: ts, Type: "stdout", Data: "one\ntw"}
+	results <- SlicerExecWriteResult{Timestamp: ts, Type: "stderr", Data: "warn\n"}
+	results <- SlicerExecWriteResult{Timestamp: ts, Type: "stdout", Data: "o"}
+	results <- SlicerExecWriteResult{Timestamp: ts, Type: "exit"}
+	close(results)
+
+	var buf bytes.Buffer
+	if err := WriteExecJSONL(&buf, results); err != nil {
+		t.Fatalf("WriteExecJSONL() error = %v", err)
+	}
+
+	want := `{"timestamp":"2026-01-01T10:00:00Z","stream":"stdout","text":"one"}
+{"timestamp":"2026-01-01T10:00:00Z","stream":"stderr","text":"warn"}
+{"timestamp":"2026-01-01T10:00:00Z","stream":"stdout","text":"two"}
+`
+	if buf.String() != want {
+		t.Fatalf("Want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteLogsJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteLogsJSONL(&buf, SlicerLogsResponse{Hostname: "vm-1", Content: "booting\nready\n"})
+	if err != nil {
+		t.Fatalf("WriteLogsJSONL() error = %v", err)
+	}
+
+	want := `{"stream":"log","text":"booting","node":"vm-1"}
+{"stream":"log","text":"ready","node":"vm-1"}
+`
+	if buf.String() != want {
+		t.Fatalf("Want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,95 @@
+package slicer
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// nextCursorHeader carries the cursor for the next page of a paginated
+// listing. It is absent or empty on the last page.
+const nextCursorHeader = "X-Slicer-Next-Cursor"
+
+// PageOptions requests a single page of a paginated listing.
+type PageOptions struct {
+	// Limit caps the number of items in the page. Zero means the server
+	// default.
+	Limit int
+	// Cursor is the next cursor returned with the previous page. Empty
+	// requests the first page.
+	Cursor string
+}
+
+func (p PageOptions) set(q url.Values) {
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+}
+
+// ListVMsPage fetches one page of VMs, filtered by opts. It returns the
+// cursor for the next page, which is empty once the last page is reached.
+func (c *SlicerClient) ListVMsPage(ctx context.Context, opts ListOptions, page PageOptions) ([]SlicerNode, string, error) {
+	return c.listVMs(ctx, opts, page)
+}
+
+// ListSecretsPage fetches one page of secrets. It returns the cursor for
+// the next page, which is empty once the last page is reached.
+func (c *SlicerClient) ListSecretsPage(ctx context.Context, page PageOptions) ([]Secret, string, error) {
+	return c.listSecrets(ctx, page)
+}
+
+// ListVMsIter iterates over all VMs, fetching pages of pageSize on demand.
+// Optional filters work the same as ListVMs. Iteration stops at the first
+// error, which is yielded with a zero SlicerNode.
+//
+// Usage:
+//
+//	for node, err := range client.ListVMsIter(ctx, 100) {
+//		if err != nil {
+//			break
+//		}
+//		// consume node
+//	}
+func (c *SlicerClient) ListVMsIter(ctx context.Context, pageSize int, opts ...ListOptions) iter.Seq2[SlicerNode, error] {
+	return paginate(func(page PageOptions) ([]SlicerNode, string, error) {
+		return c.listVMs(ctx, firstListOption(opts), page)
+	}, pageSize)
+}
+
+// ListSecretsIter iterates over all secrets, fetching pages of pageSize on
+// demand. Iteration stops at the first error, which is yielded with a zero
+// Secret.
+func (c *SlicerClient) ListSecretsIter(ctx context.Context, pageSize int) iter.Seq2[Secret, error] {
+	return paginate(func(page PageOptions) ([]Secret, string, error) {
+		return c.listSecrets(ctx, page)
+	}, pageSize)
+}
+
+// paginate adapts a page fetcher into an iterator that follows next cursors
+// until the last page.
+func paginate[T any](fetch func(PageOptions) ([]T, string, error), pageSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		page := PageOptions{Limit: pageSize}
+		for {
+			items, next, err := fetch(page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" || next == page.Cursor {
+				return
+			}
+			page.Cursor = next
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package slicer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListSecretsIter_FollowsCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("limit"); got != "2" {
+			t.Errorf("Want limit=2, got %q", got)
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set(nextCursorHeader, "page-2")
+			_, _ = io.WriteString(w, `[{"name":"a"},{"name":"b"}]`)
+		case "page-2":
+			_, _ = io.WriteString(w, `[{"name":"c"}]`)
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	var names []string
+	for secret, err := range client.ListSecretsIter(context.Background(), 2) {
+		if err != nil {
+			t.Fatalf("ListSecretsIter() error = %v", err)
+		}
+		names = append(names, secret.Name)
+	}
+
+	if len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Fatalf("Want [a b c], got %v", names)
+	}
+}
+
+func TestListVMsPage_SendsFiltersAndPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("tag") != "ci" || q.Get("limit") != "10" || q.Get("cursor") != "abc" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `[{"hostname":"vm-1"}]`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	nodes, next, err := client.ListVMsPage(context.Background(), ListOptions{Tag: "ci"}, PageOptions{Limit: 10, Cursor: "abc"})
+	if err != nil {
+		t.Fatalf("ListVMsPage() error = %v", err)
+	}
+	if len(nodes) != 1 || next != "" {
+		t.Fatalf("Want 1 node and no next cursor, got %d and %q", len(nodes), next)
+	}
+}
@@ -0,0 +1,61 @@
+package slicer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParsePermissions parses a Unix permission string as accepted by the copy,
+// file and secret APIs. "600", "0600" and "0o600" all mean the same mode,
+// and surrounding spaces are ignored. Strings that are not octal or that set
+// bits above 07777 are rejected. Setuid, setgid and sticky bits map to their
+// os.FileMode flags.
+func ParsePermissions(s string) (os.FileMode, error) {
+	bits, err := parsePermissionBits(s)
+	if err != nil {
+		return 0, err
+	}
+
+	mode := os.FileMode(bits & 0o777)
+	if bits&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// normalizePermissions checks s with ParsePermissions and returns it in the
+// plain octal form sent to the server, e.g. "600" for "0o600". An empty s
+// is returned as is.
+func normalizePermissions(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	bits, err := parsePermissionBits(s)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(bits, 8), nil
+}
+
+func parsePermissionBits(s string) (uint64, error) {
+	v := strings.TrimSpace(s)
+	if len(v) > 2 && v[0] == '0' && (v[1] == 'o' || v[1] == 'O') {
+		v = v[2:]
+	}
+	if v == "" || strings.ContainsAny(v, "+-_") {
+		return 0, fmt.Errorf("invalid permissions %q", s)
+	}
+	bits, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || bits > 0o7777 {
+		return 0, fmt.Errorf("invalid permissions %q: want an octal mode such as 0644", s)
+	}
+	return bits, nil
+}
@@ -0,0 +1,52 @@
+package slicer
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{in: "600", want: 0o600},
+		{in: "0600", want: 0o600},
+		{in: "0o600", want: 0o600},
+		{in: " 0O600 ", want: 0o600},
+		{in: "4755", want: os.ModeSetuid | 0o755},
+		{in: "0o", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "rw-", wantErr: true},
+		{in: "0x180", wantErr: true},
+		{in: "+600", wantErr: true},
+		{in: "0800", wantErr: true},
+		{in: "17777", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePermissions(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParsePermissions(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("ParsePermissions(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePermissions_CopyAndSecretsAgree(t *testing.T) {
+	for _, in := range []string{"600", "0600", "0o600"} {
+		got, err := normalizePermissions(in)
+		if err != nil {
+			t.Fatalf("normalizePermissions(%q) error = %v", in, err)
+		}
+		if got != "600" {
+			t.Fatalf("normalizePermissions(%q) = %q, want %q", in, got, "600")
+		}
+	}
+	if _, err := normalizePermissions("0o900"); err == nil {
+		t.Fatal("Want error for non-octal permissions")
+	}
+}
@@ -213,7 +213,7 @@ func (c *SlicerClient) proxyDo(ctx context.Context, method, path string, body an
 	defer resp.Body.Close()
 	if resp.StatusCode != wantStatus {
 		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, b)
+		return fmt.Errorf("%s %s: %w", method, path, newAPIError(resp, b))
 	}
 	if out != nil {
 		return json.NewDecoder(resp.Body).Decode(out)
@@ -0,0 +1,79 @@
+package slicer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// RecordedRequest is an outbound request captured by RecordingTransport.
+type RecordedRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// RecordingTransport is an http.RoundTripper that records every outbound
+// request and answers it without touching the network. It lets tests of
+// code built on the SDK assert on requests without an httptest server:
+//
+//	rt := &slicer.RecordingTransport{}
+//	client := slicer.NewSlicerClient("http://slicer", "token", "test", nil, slicer.WithRoundTripper(rt))
+//	_ = client.PauseVM(ctx, "vm-1")
+//	reqs := rt.Requests() // reqs[0].URL.Path == "/vm/vm-1/pause"
+type RecordingTransport struct {
+	// Respond builds the response for each request. If nil, an empty
+	// 200 OK response is returned.
+	Respond func(req *http.Request) (*http.Response, error)
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// RoundTrip records req and returns the response from Respond.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL,
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	t.mu.Unlock()
+
+	if t.Respond != nil {
+		return t.Respond(req)
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// Requests returns a copy of the requests recorded so far.
+func (t *RecordingTransport) Requests() []RecordedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedRequest(nil), t.requests...)
+}
@@ -0,0 +1,74 @@
+package slicer
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxCachedResponses bounds the entries kept by WithResponseCache, e.g.
+// when a dashboard pages through a large fleet with changing cursors.
+const maxCachedResponses = 128
+
+// WithResponseCache keeps the last body and ETag of each typed GET, such
+// as ListVMs and GetHostGroups, and revalidates it with If-None-Match on
+// the next call. When the server answers 304 Not Modified the cached body
+// is decoded again and returned as if it had been sent, which saves
+// bandwidth for dashboards that poll every few seconds.
+//
+// Responses without an ETag are not cached, so servers that do not send
+// one behave exactly as without the option. Each call still makes a
+// request; only the payload is saved.
+func WithResponseCache() ClientOption {
+	return func(c *SlicerClient) {
+		c.responseCache = &responseCache{entries: map[string]cachedResponse{}}
+	}
+}
+
+// responseCache holds the cached responses for WithResponseCache, keyed by
+// request URL.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	etag   string
+	body   []byte
+	header http.Header
+}
+
+// prepare adds If-None-Match to req when a response for its URL is cached.
+func (rc *responseCache) prepare(req *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if entry, ok := rc.entries[req.URL.String()]; ok {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+}
+
+// lookup returns the cached response for a 304 answer to req.
+func (rc *responseCache) lookup(req *http.Request) (cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[req.URL.String()]
+	return entry, ok
+}
+
+// store caches a successful response to req if it carries an ETag.
+func (rc *responseCache) store(req *http.Request, res *http.Response, body []byte) {
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	key := req.URL.String()
+	if _, ok := rc.entries[key]; !ok && len(rc.entries) >= maxCachedResponses {
+		for k := range rc.entries {
+			delete(rc.entries, k)
+			break
+		}
+	}
+	rc.entries[key] = cachedResponse{etag: etag, body: body, header: res.Header.Clone()}
+}
@@ -0,0 +1,104 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWithResponseCache(t *testing.T) {
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `[{"hostname":"vm-1"},{"hostname":"vm-2"}]`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil, WithResponseCache())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		nodes, err := client.ListVMs(ctx)
+		if err != nil {
+			t.Fatalf("ListVMs() call %d error = %v", i, err)
+		}
+		if len(nodes) != 2 || nodes[1].Hostname != "vm-2" {
+			t.Fatalf("ListVMs() call %d = %v, want the cached nodes", i, nodes)
+		}
+		// Callers own the result; changes must not leak into the cache.
+		nodes[1].Hostname = "changed"
+	}
+	if full != 1 || notModified != 2 {
+		t.Fatalf("Want 1 full response and 2 revalidations, got %d and %d", full, notModified)
+	}
+
+	// Each URL is cached separately.
+	if _, err := client.ListVMs(ctx, ListOptions{Tag: "web"}); err != nil {
+		t.Fatalf("ListVMs(tag) error = %v", err)
+	}
+	if full != 2 {
+		t.Fatalf("Want a full response for a new URL, got %d", full)
+	}
+
+	// Without the option no conditional request is sent.
+	full = 0
+	plain := NewSlicerClient(server.URL, "token", "test-agent", nil)
+	for i := 0; i < 2; i++ {
+		if _, err := plain.ListVMs(ctx); err != nil {
+			t.Fatalf("ListVMs() error = %v", err)
+		}
+	}
+	if full != 2 {
+		t.Fatalf("Want every response sent in full without the cache, got %d", full)
+	}
+}
+
+func TestWithResponseCache_SkipsBadBodies(t *testing.T) {
+	bodies := []io.Reader{
+		io.MultiReader(strings.NewReader(`[{"hostname":"vm-1"}`), iotest.ErrReader(errors.New("connection reset"))),
+		strings.NewReader(`[{"hostname":`),
+		strings.NewReader(`[{"hostname":"vm-1"}]`),
+	}
+	var conditional []string
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		conditional = append(conditional, req.Header.Get("If-None-Match"))
+		body := bodies[0]
+		bodies = bodies[1:]
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Etag": []string{`"v1"`}, "Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(body),
+			Request:    req,
+		}, nil
+	})}
+
+	client := NewSlicerClient("http://slicer.test", "token", "test-agent", httpClient, WithResponseCache())
+	ctx := context.Background()
+
+	if _, err := client.ListVMs(ctx); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("Want the read error, got %v", err)
+	}
+	if _, err := client.ListVMs(ctx); err == nil {
+		t.Fatal("Want a decode error for a truncated body")
+	}
+	if _, err := client.ListVMs(ctx); err != nil {
+		t.Fatalf("ListVMs() error = %v", err)
+	}
+	for i, etag := range conditional {
+		if etag != "" {
+			t.Fatalf("Request %d revalidated %s, but no good body was cached", i, etag)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package slicer
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxResponseHookBody caps how much of a response body is captured for a
+// response hook, so streamed downloads and exec output are not buffered.
+const maxResponseHookBody = 64 * 1024
+
+// ResponseInfo describes a response passed to the hook set by
+// WithResponseHook.
+type ResponseInfo struct {
+	Method     string
+	URL        *url.URL
+	StatusCode int
+	Header     http.Header
+	// Body holds up to the first 64 KiB of the body as received, before
+	// the SDK decompresses it when WithDisableAutoDecompress is set.
+	Body []byte
+	// Truncated is true when the body was longer than Body.
+	Truncated bool
+}
+
+// WithResponseHook passes every response to fn once its body has been
+// consumed and closed, e.g. to log what the server sent in a support case.
+// Bodies are captured as they stream through, so long-running responses
+// such as Exec reach fn only when they end.
+//
+// By default net/http asks for gzip and decompresses transparently, so
+// the captured body is already decompressed. Combine with
+// WithDisableAutoDecompress to capture the bytes exactly as sent.
+func WithResponseHook(fn func(ResponseInfo)) ClientOption {
+	return func(c *SlicerClient) {
+		c.responseHook = fn
+	}
+}
+
+// WithDisableAutoDecompress stops net/http from decompressing responses
+// behind the SDK's back. The client then sends Accept-Encoding: gzip itself
+// unless a request already carries an Accept-Encoding header, hands the
+// compressed body to the WithResponseHook hook, and decompresses it for
+// the SDK afterwards, so methods behave the same either way.
+//
+// Like WithDryRun, it applies regardless of its position relative to
+// WithRoundTripper, and wraps whatever transport the client ends up with.
+func WithDisableAutoDecompress() ClientOption {
+	return func(c *SlicerClient) {
+		c.rawResponses = true
+	}
+}
+
+// responseTransport implements WithResponseHook and
+// WithDisableAutoDecompress around next.
+type responseTransport struct {
+	next http.RoundTripper
+	hook func(ResponseInfo)
+	raw  bool
+}
+
+func (t *responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.raw && req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.Body == nil {
+		return res, err
+	}
+
+	if t.hook != nil {
+		res.Body = &hookedBody{
+			body: res.Body,
+			info: ResponseInfo{
+				Method:     req.Method,
+				URL:        req.URL,
+				StatusCode: res.StatusCode,
+				Header:     res.Header.Clone(),
+			},
+			hook: t.hook,
+		}
+	}
+
+	if t.raw && strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") && req.Method != http.MethodHead {
+		res.Body = &gzipBody{body: res.Body}
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Uncompressed = true
+	}
+
+	return res, nil
+}
+
+// CloseIdleConnections forwards to the wrapped transport so Close keeps
+// working for clients that own their transport.
+func (t *responseTransport) CloseIdleConnections() {
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// hookedBody captures the start of a body as it is read and passes it to
+// hook when the body is closed.
+type hookedBody struct {
+	body io.ReadCloser
+	info ResponseInfo
+	hook func(ResponseInfo)
+	once sync.Once
+}
+
+func (b *hookedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if room := maxResponseHookBody - len(b.info.Body); room > 0 {
+		b.info.Body = append(b.info.Body, p[:min(n, room)]...)
+		if n > room {
+			b.info.Truncated = true
+		}
+	} else if n > 0 {
+		b.info.Truncated = true
+	}
+	return n, err
+}
+
+func (b *hookedBody) Close() error {
+	err := b.body.Close()
+	b.once.Do(func() { b.hook(b.info) })
+	return err
+}
+
+// gzipBody decompresses body, reading the gzip header on first use.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,51 @@
+package slicer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithDisableAutoDecompress_HookSeesRawBody(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"version":"1.2.3"}`))
+	zw.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Want Accept-Encoding: gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	var seen []ResponseInfo
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil,
+		WithResponseHook(func(info ResponseInfo) { seen = append(seen, info) }),
+		WithDisableAutoDecompress(),
+	)
+
+	info, err := client.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo() error = %v", err)
+	}
+	if info.Version != "1.2.3" {
+		t.Fatalf("Want version decoded after decompression, got %q", info.Version)
+	}
+
+	if len(seen) != 1 {
+		t.Fatalf("Want one response passed to the hook, got %d", len(seen))
+	}
+	if !bytes.Equal(seen[0].Body, compressed.Bytes()) {
+		t.Fatalf("Want the compressed body in the hook, got %q", seen[0].Body)
+	}
+	if seen[0].StatusCode != http.StatusOK || seen[0].Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Want status and headers as sent, got %d %v", seen[0].StatusCode, seen[0].Header)
+	}
+}
@@ -0,0 +1,130 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// WithRetry retries requests that fail transiently, making up to attempts
+// tries in total and waiting backoff before the first retry, doubling for
+// each one after. attempts of one or less disables retries, and a zero
+// backoff defaults to 200ms.
+//
+// Which failures are retried:
+//
+//   - Connection refused and DNS lookup errors that are temporary or timed
+//     out, for every method, since the request never reached the server.
+//   - Timeouts and connection resets, see IsRetryableError, and 429, 502,
+//     503 and 504 responses, for GET, HEAD, OPTIONS, PUT and DELETE only,
+//     since a POST or PATCH may already have been applied.
+//
+// Requests whose body cannot be replayed, such as tar uploads, and requests
+// whose context is done are never retried. Like WithDryRun, it applies
+// regardless of its position relative to WithRoundTripper.
+func WithRetry(attempts int, backoff time.Duration) ClientOption {
+	return func(c *SlicerClient) {
+		c.retryAttempts = attempts
+		c.retryBackoff = backoff
+	}
+}
+
+// IsRetryableError reports whether err, as returned from an HTTP round
+// trip, is a transient network failure worth retrying: a timeout,
+// connection refused, connection reset or aborted, or a temporary or timed
+// out DNS lookup. Context cancellation and deadline errors are not
+// retryable.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isUnsentError(err) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isUnsentError reports whether err means the request never reached the
+// server, so any method can be retried safely.
+func isUnsentError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// retryTransport implements WithRetry around next.
+type retryTransport struct {
+	next     http.RoundTripper
+	attempts int
+	backoff  time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idempotent := false
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		idempotent = true
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	wait := t.backoff
+	for attempt := 1; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+
+		last := attempt >= t.attempts || !replayable || req.Context().Err() != nil
+		if last {
+			return res, err
+		}
+		if err != nil {
+			if !isUnsentError(err) && !(idempotent && IsRetryableError(err)) {
+				return res, err
+			}
+		} else {
+			switch res.StatusCode {
+			case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				if !idempotent {
+					return res, nil
+				}
+				drainClose(res.Body)
+			default:
+				return res, nil
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		wait *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// CloseIdleConnections forwards to the wrapped transport so Close keeps
+// working for clients that own their transport.
+func (t *retryTransport) CloseIdleConnections() {
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
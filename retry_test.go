@@ -0,0 +1,97 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "dns blip", err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, want: true},
+		{name: "dns not found", err: &net.DNSError{Err: "no such host", IsNotFound: true}, want: false},
+		{name: "timeout", err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, want: true},
+		{name: "canceled", err: fmt.Errorf("request: %w", context.Canceled), want: false},
+		{name: "other", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryableError(tt.err); got != tt.want {
+			t.Fatalf("%s: IsRetryableError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry_RetriesIdempotentStatus(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method]++
+		if calls[r.Method] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil, WithRetry(3, time.Millisecond))
+
+	info, err := client.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo() error = %v", err)
+	}
+	if info.Version != "1.0.0" || calls[http.MethodGet] != 2 {
+		t.Fatalf("Want success on the second GET, got %q after %d calls", info.Version, calls[http.MethodGet])
+	}
+
+	// A POST may have been applied, so a 503 is returned as is.
+	if err := client.CreateSecret(context.Background(), CreateSecretRequest{Name: "s", Data: "d"}); err == nil {
+		t.Fatal("Want the 503 returned for POST")
+	}
+	if calls[http.MethodPost] != 1 {
+		t.Fatalf("Want POST sent once, got %d", calls[http.MethodPost])
+	}
+}
+
+func TestWithRetry_RetriesRefusedConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	attempts := 0
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := NewSlicerClient("http://"+addr, "token", "test-agent", nil, WithRoundTripper(rt), WithRetry(3, time.Millisecond))
+
+	if err := client.CreateSecret(context.Background(), CreateSecretRequest{Name: "s", Data: "d"}); err == nil {
+		t.Fatal("Want error for a refused connection")
+	}
+	if attempts != 3 {
+		t.Fatalf("Want 3 attempts for a refused POST, got %d", attempts)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -3,100 +3,323 @@ package slicer
 import (
 	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 )
 
+// ErrTruncatedArchive is returned when a tar stream ends before its
+// end-of-archive marker, e.g. because a download was cut short, so an
+// incomplete extraction is not mistaken for success.
+var ErrTruncatedArchive = errors.New("tar archive is truncated")
+
+// StreamTarOptions controls how StreamTarArchiveWithOptions builds an archive.
+type StreamTarOptions struct {
+	// ExcludePatterns are glob patterns of paths to skip.
+	ExcludePatterns []string
+
+	// PreserveModes archives each file's exact permission bits. By default
+	// any executable bit is widened to 0111.
+	PreserveModes bool
+
+	// FollowSymlinks archives the file or directory a symlink points to
+	// under the link's name, like tar -h, instead of skipping the link.
+	// Links that would recurse into one of their own parent directories
+	// and dangling links are skipped.
+	FollowSymlinks bool
+
+	// SkipUnreadable leaves out files and directories that cannot be read,
+	// such as those denied by permissions, instead of failing the archive.
+	// An unreadable directory is archived empty. Errors after a file's
+	// contents have started streaming still fail the archive.
+	SkipUnreadable bool
+
+	// OnSkip is called with the relative path and error of each entry left
+	// out by SkipUnreadable, e.g. to collect them for reporting.
+	OnSkip func(relPath string, err error)
+
+	// OnSkipSpecial is called with the relative path and mode of each
+	// entry left out because it is not a regular file or directory, such
+	// as a symlink, device, FIFO or socket, so callers can warn about it.
+	// With FollowSymlinks it reports dangling links and links back to a
+	// parent directory with os.ModeSymlink, and links to special files
+	// with the target's mode. Excluded entries are not reported.
+	OnSkipSpecial func(relPath string, mode os.FileMode)
+}
+
+// skipSpecial passes an entry left out for its type to OnSkipSpecial.
+func (o StreamTarOptions) skipSpecial(relPath string, mode os.FileMode) {
+	if o.OnSkipSpecial != nil {
+		o.OnSkipSpecial(relPath, mode)
+	}
+}
+
+// skip reports whether an error reading relPath should be ignored under
+// SkipUnreadable, passing it to OnSkip if so.
+func (o StreamTarOptions) skip(ctx context.Context, relPath string, err error) bool {
+	if !o.SkipUnreadable || ctx.Err() != nil {
+		return false
+	}
+	if o.OnSkip != nil {
+		o.OnSkip(relPath, err)
+	}
+	return true
+}
+
 // StreamTarArchive streams a tar archive of regular files and directories to w.
 // Only handles regular files and directories. Preserves mtime and executable bit.
-// Skips symlinks, devices, and other special files.
+// Skips symlinks, devices, and other special files; see
+// StreamTarOptions.FollowSymlinks to archive symlink targets instead, and
+// StreamTarOptions.OnSkipSpecial to be told about them.
 func StreamTarArchive(ctx context.Context, w io.Writer, parentDir, baseName string, excludePatterns ...string) error {
+	return StreamTarArchiveWithOptions(ctx, w, parentDir, baseName, StreamTarOptions{
+		ExcludePatterns: excludePatterns,
+	})
+}
+
+// StreamTarArchiveWithOptions is like StreamTarArchive but takes a
+// StreamTarOptions, e.g. to keep exact file modes with PreserveModes.
+func StreamTarArchiveWithOptions(ctx context.Context, w io.Writer, parentDir, baseName string, opts StreamTarOptions) error {
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
-	sourcePath := filepath.Join(parentDir, baseName)
-	excludes := normalizeExcludePatterns(excludePatterns...)
+	return walkTarEntries(ctx, filepath.Join(parentDir, baseName), opts, func(e tarEntry) error {
+		return writeTarEntry(ctx, tw, e, opts)
+	})
+}
 
-	return filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+// tarEntry is a file or directory to be archived, with its path relative
+// to the source directory in slash form.
+type tarEntry struct {
+	path    string
+	relPath string
+	info    os.FileInfo
+}
+
+// walkTarEntries calls fn for every regular file and directory below
+// sourcePath that is not excluded, in lexical order.
+func walkTarEntries(ctx context.Context, sourcePath string, opts StreamTarOptions, fn func(tarEntry) error) error {
+	w := &tarWalker{
+		ctx:      ctx,
+		opts:     opts,
+		excludes: normalizeExcludePatterns(opts.ExcludePatterns...),
+		fn:       fn,
+	}
 
+	realRoot := sourcePath
+	if opts.FollowSymlinks {
+		resolved, err := filepath.EvalSymlinks(sourcePath)
 		if err != nil {
 			return err
 		}
+		realRoot = resolved
+	}
+	return w.walk(sourcePath, realRoot, "", nil)
+}
 
-		// Skip non-regular files and non-directories
-		if !info.Mode().IsRegular() && !info.IsDir() {
-			return nil
+// tarWalker walks a source tree for walkTarEntries, descending into
+// symlinked directories when FollowSymlinks is set.
+type tarWalker struct {
+	ctx      context.Context
+	opts     StreamTarOptions
+	excludes []string
+	fn       func(tarEntry) error
+}
+
+// tarWalkLevel records the real paths of one walk level that led to a
+// followed symlink: the level's root and the directory holding the link.
+// Together they span the real directories that enclose the link.
+type tarWalkLevel struct {
+	root    string
+	linkDir string
+}
+
+// walk archives the tree at root, whose real path is realRoot, naming its
+// entries below relPrefix. outer holds the levels of the symlinks followed
+// to get here, for loop detection.
+func (w *tarWalker) walk(root, realRoot, relPrefix string, outer []tarWalkLevel) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		select {
+		case <-w.ctx.Done():
+			return w.ctx.Err()
+		default:
 		}
 
 		// Make paths relative to sourcePath (not parentDir) so that copying /etc
 		// creates entries like "passwd" not "etc/passwd"
-		relPath, err := filepath.Rel(sourcePath, path)
+		relPath, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return fmt.Errorf("failed to get relative path: %w", relErr)
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPrefix != "" {
+			if relPath == "." {
+				relPath = relPrefix
+			} else {
+				relPath = relPrefix + "/" + relPath
+			}
+		}
+
 		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
+			// The source itself must be readable, entries below it may
+			// be skipped.
+			if relPath == "." || !w.opts.skip(w.ctx, relPath, err) {
+				return err
+			}
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		// Skip the source directory itself
-		if relPath == "." {
+		if path == root {
 			return nil
 		}
 
-		relPath = filepath.ToSlash(relPath)
-		if shouldExcludePath(relPath, excludes) {
+		if info.Mode()&os.ModeSymlink != 0 && w.opts.FollowSymlinks {
+			if shouldExcludePath(relPath, w.excludes) {
+				return nil
+			}
+			linkDir := filepath.Join(realRoot, strings.TrimPrefix(filepath.Dir(path), root))
+			return w.follow(path, relPath, append(outer, tarWalkLevel{root: realRoot, linkDir: linkDir}))
+		}
+
+		// Skip non-regular files and non-directories
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			if !shouldExcludePath(relPath, w.excludes) {
+				w.opts.skipSpecial(relPath, info.Mode())
+			}
+			return nil
+		}
+
+		if shouldExcludePath(relPath, w.excludes) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Create header with normalized permissions (strip setuid/setgid/sticky)
-		mode := info.Mode().Perm()
-		if info.Mode().IsRegular() && info.Mode()&0111 != 0 {
-			// Preserve executable bit
-			mode |= 0111
-		}
+		return w.fn(tarEntry{path: path, relPath: relPath, info: info})
+	})
+}
 
-		header := &tar.Header{
-			Name:    relPath,
-			Size:    info.Size(),
-			Mode:    int64(mode),
-			ModTime: info.ModTime(),
-		}
+// follow archives the target of the symlink at path under relPath.
+func (w *tarWalker) follow(path, relPath string, levels []tarWalkLevel) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Dangling link
+		w.opts.skipSpecial(relPath, os.ModeSymlink)
+		return nil
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		w.opts.skipSpecial(relPath, os.ModeSymlink)
+		return nil
+	}
 
-		if info.IsDir() {
-			header.Typeflag = tar.TypeDir
-			header.Name += "/"
-		} else {
-			header.Typeflag = tar.TypeReg
+	switch {
+	case info.Mode().IsRegular():
+		return w.fn(tarEntry{path: path, relPath: relPath, info: info})
+	case info.IsDir():
+		for _, l := range levels {
+			if pathWithin(target, l.root) && pathWithin(l.linkDir, target) {
+				// The link points at one of its own parents.
+				w.opts.skipSpecial(relPath, os.ModeSymlink)
+				return nil
+			}
 		}
-
-		if err := tw.WriteHeader(header); err != nil {
-			return fmt.Errorf("failed to write tar header for %s: %w", path, err)
+		if err := w.fn(tarEntry{path: path, relPath: relPath, info: info}); err != nil {
+			return err
 		}
+		return w.walk(target, target, relPath, levels)
+	}
+	w.opts.skipSpecial(relPath, info.Mode())
+	return nil
+}
 
-		// Stream file contents
-		if info.Mode().IsRegular() {
-			f, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf("failed to open file %s: %w", path, err)
-			}
-			_, err = io.Copy(tw, f)
-			f.Close()
-			if err != nil {
-				return fmt.Errorf("failed to write file contents for %s: %w", path, err)
+// pathWithin reports whether p is dir or lies below it.
+func pathWithin(p, dir string) bool {
+	if p == dir {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// writeTarEntry writes the header and, for regular files, the contents of e.
+// The contents are read through ctx so cancellation interrupts a large file
+// mid-copy rather than waiting for the next entry.
+func writeTarEntry(ctx context.Context, tw *tar.Writer, e tarEntry, opts StreamTarOptions) error {
+	info := e.info
+
+	// Open before writing the header, so an unreadable file can be left
+	// out without corrupting the archive.
+	var f *os.File
+	if info.Mode().IsRegular() {
+		var err error
+		f, err = os.Open(e.path)
+		if err != nil {
+			if opts.skip(ctx, e.relPath, err) {
+				return nil
 			}
+			return fmt.Errorf("failed to open file %s: %w", e.path, err)
 		}
+		defer f.Close()
+	}
 
-		return nil
-	})
+	// Create header with normalized permissions (strip setuid/setgid/sticky)
+	mode := info.Mode().Perm()
+	if !opts.PreserveModes && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+		// Preserve executable bit
+		mode |= 0111
+	}
+
+	header := &tar.Header{
+		Name:    e.relPath,
+		Size:    info.Size(),
+		Mode:    int64(mode),
+		ModTime: info.ModTime(),
+	}
+
+	if info.IsDir() {
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+	} else {
+		header.Typeflag = tar.TypeReg
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", e.path, err)
+	}
+
+	// Stream file contents
+	if f != nil {
+		if _, err := io.Copy(tw, &contextReader{ctx: ctx, r: f}); err != nil {
+			return fmt.Errorf("failed to write file contents for %s: %w", e.path, err)
+		}
+	}
+
+	return nil
+}
+
+// streamTarEntries writes a tar archive of entries to w.
+func streamTarEntries(ctx context.Context, w io.Writer, entries []tarEntry, opts StreamTarOptions) error {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeTarEntry(ctx, tw, e, opts); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
 }
 
 func shouldExcludePath(relPath string, excludes []string) bool {
@@ -208,13 +431,122 @@ func splitPattern(input string) []string {
 	return strings.Split(filepath.ToSlash(input), "/")
 }
 
+// extractedDir records a directory whose mode and mtime are applied after
+// all tar entries have been extracted.
+type extractedDir struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// ExtractTarOptions tunes ExtractTarStreamWithOptions and ExtractTarToPathWithOptions.
+type ExtractTarOptions struct {
+	// UID and GID are the ownership applied to extracted entries. Chown is
+	// only attempted if either is non-zero.
+	UID uint32
+	GID uint32
+
+	// NoChown skips ownership changes entirely, regardless of UID/GID.
+	// Use this in rootless containers where chown always fails. Note that
+	// CpFromVM defaults UID/GID to the current user, so without NoChown
+	// a non-root caller still pays for one chown per entry.
+	NoChown bool
+
+	// ExcludePatterns are glob patterns of entries to skip.
+	ExcludePatterns []string
+
+	// NoOverwrite makes extraction fail with an error wrapping os.ErrExist
+	// instead of replacing a file that already exists at the destination.
+	// Existing directories are still merged into.
+	NoOverwrite bool
+
+	// SkipUnchanged leaves an existing regular file in place when its size
+	// and mtime (to the second) already match the tar header, giving
+	// rsync-like incremental updates. The permissions and ownership of
+	// skipped files are still reconciled.
+	SkipUnchanged bool
+
+	// StrictPaths validates entry names with ValidRelPathStrict instead of
+	// ValidRelPath. It is always on when extracting on Windows.
+	StrictPaths bool
+
+	// PreserveSpecialBits restores each entry's exact mode from the tar
+	// header, including setuid, setgid and sticky bits, instead of
+	// normalizing it. This is for faithful restores of trusted system
+	// images, e.g. keeping /usr/bin/sudo setuid.
+	//
+	// DANGER: an untrusted archive can use this to plant setuid binaries
+	// owned by UID, which is root by default. Only enable it for archives
+	// you created yourself.
+	PreserveSpecialBits bool
+
+	// Staged makes ExtractTarToPathWithOptions, when dest is an existing
+	// directory, extract into a temporary sibling directory and swap it in
+	// place of dest only once extraction succeeds, so a failed or cancelled
+	// copy leaves the previous tree untouched. The old tree is removed, so
+	// files missing from the archive do not survive. The swap is two
+	// renames: dest is briefly absent, but never partially written.
+	//
+	// Renames cannot cross filesystems, so if dest is a mount point or
+	// otherwise on a different filesystem from its parent, extraction falls
+	// back to in place, with no protection against partial writes.
+	// Staged cannot be combined with NoOverwrite.
+	Staged bool
+
+	// Sync flushes each extracted file to disk before closing it, and each
+	// directory written to once extraction finishes, so a restore that
+	// reports success survives a crash. It is off by default as it slows
+	// down extracting many small files considerably.
+	Sync bool
+
+	// AllowMissingTrailer accepts archives that end cleanly between
+	// entries without the end-of-archive marker, as some tar writers
+	// produce. By default such a stream is reported as ErrTruncatedArchive,
+	// since it cannot be told apart from a download that was cut short
+	// between two files.
+	AllowMissingTrailer bool
+}
+
+// tarModeMask selects the permission and special bits restored from tar
+// headers when PreserveSpecialBits is set.
+const tarModeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// unchangedFile reports whether target is a regular file whose size and
+// mtime match header, compared to the second as tar mtimes usually are.
+func unchangedFile(target string, header *tar.Header) bool {
+	info, err := os.Lstat(target)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return info.Size() == header.Size &&
+		info.ModTime().Truncate(time.Second).Equal(header.ModTime.Truncate(time.Second))
+}
+
+// chown reports whether ownership should be applied to extracted entries.
+func (o ExtractTarOptions) chown() bool {
+	return !o.NoChown && (o.UID > 0 || o.GID > 0)
+}
+
 // ExtractTarStream extracts a tar stream from r into extractDir.
 // Only handles regular files and directories. Preserves mtime and executable bit.
-// Normalizes permissions (strips setuid/setgid/sticky bits). Skips all other entry types.
+// Normalizes permissions (strips setuid/setgid/sticky bits) unless
+// ExtractTarOptions.PreserveSpecialBits is set. Skips all other entry types.
 // If uid or gid are non-zero, files will be chowned to that uid/gid after creation.
 // Note: Permissions are set when opening files (efficient), chown is only applied if uid/gid are non-zero.
+// Directory modes and mtimes are applied in a second pass once all entries are written.
 func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid, gid uint32, excludePatterns ...string) error {
-	excludes := normalizeExcludePatterns(excludePatterns...)
+	return ExtractTarStreamWithOptions(ctx, r, extractDir, ExtractTarOptions{
+		UID:             uid,
+		GID:             gid,
+		ExcludePatterns: excludePatterns,
+	})
+}
+
+// ExtractTarStreamWithOptions is like ExtractTarStream but takes an
+// ExtractTarOptions, e.g. to skip chown with NoChown.
+func ExtractTarStreamWithOptions(ctx context.Context, r io.Reader, extractDir string, opts ExtractTarOptions) error {
+	excludes := normalizeExcludePatterns(opts.ExcludePatterns...)
+	uid, gid := opts.UID, opts.GID
 
 	absExtractDir, err := filepath.Abs(extractDir)
 	if err != nil {
@@ -222,8 +554,17 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 	}
 	absExtractDir = filepath.Clean(absExtractDir) + string(filepath.Separator)
 
-	tr := tar.NewReader(r)
+	validName := ValidRelPath
+	if opts.StrictPaths || runtime.GOOS == "windows" {
+		validName = ValidRelPathStrict
+	}
+
+	trailer := &trailerReader{r: r, zeroAt: -1}
+	tr := tar.NewReader(trailer)
 	madeDir := make(map[string]bool)
+	var dirs []extractedDir
+	// Directories whose entries changed, flushed at the end when opts.Sync.
+	touchedDirs := map[string]bool{filepath.Clean(extractDir): true}
 
 	for {
 		select {
@@ -232,17 +573,33 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 		default:
 		}
 
+		// Finish the previous entry first, so that the end-of-archive
+		// marker can be told apart from zeros at the end of its data.
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("failed to read tar entry: %w: %w", ErrTruncatedArchive, err)
+			}
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+		headerAt := (trailer.off + 511) / 512 * 512
+
 		header, err := tr.Next()
 		if err == io.EOF {
+			if trailer.zeroAt < headerAt && !opts.AllowMissingTrailer {
+				return fmt.Errorf("no end-of-archive marker after %d bytes: %w", trailer.off, ErrTruncatedArchive)
+			}
 			break
 		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read tar header: %w: %w", ErrTruncatedArchive, err)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 
 		// Validate path
 		name := strings.TrimSuffix(header.Name, "/")
-		if !ValidRelPath(name) {
+		if !validName(name) {
 			return fmt.Errorf("tar contained invalid name: %q", header.Name)
 		}
 
@@ -270,22 +627,29 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 		if header.Mode&0111 != 0 {
 			mode |= 0111
 		}
+		// Directories keep their exact permission bits; widening the
+		// executable bits would defeat restrictive modes such as 0700.
+		dirMode := os.FileMode(header.Mode).Perm()
+		if opts.PreserveSpecialBits {
+			mode = header.FileInfo().Mode() & tarModeMask
+			dirMode = mode
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(target, mode); err != nil {
+			// Create writable so children can be extracted; the final mode
+			// is applied once all entries have been written.
+			if err := os.MkdirAll(target, 0o755); err != nil {
 				return fmt.Errorf("failed to create directory %s: %w", target, err)
 			}
 			madeDir[target] = true
+			dirs = append(dirs, extractedDir{path: target, mode: dirMode, modTime: header.ModTime})
+			touchedDirs[filepath.Dir(target)] = true
 			// Set ownership if requested (only on Linux, skipped on Windows)
 			// Note: We don't validate uid/gid ranges - the OS will reject invalid values
-			if uid > 0 || gid > 0 {
+			if opts.chown() {
 				os.Chown(target, int(uid), int(gid)) // Error ignored for Windows compatibility
 			}
-			// Preserve mtime
-			if !header.ModTime.IsZero() {
-				os.Chtimes(target, header.ModTime, header.ModTime)
-			}
 
 		case tar.TypeReg, tar.TypeRegA:
 			// Create parent directories
@@ -297,17 +661,45 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 				madeDir[parentDir] = true
 			}
 
-			// Remove existing file if it exists
-			os.Remove(target)
+			if opts.SkipUnchanged && unchangedFile(target, header) {
+				if opts.chown() {
+					os.Chown(target, int(uid), int(gid)) // Error ignored for Windows compatibility
+				}
+				if info, err := os.Lstat(target); err == nil && info.Mode()&tarModeMask != mode {
+					os.Chmod(target, mode)
+				}
+				continue
+			}
+
+			flags := os.O_CREATE | os.O_RDWR | os.O_TRUNC
+			if opts.NoOverwrite {
+				// O_EXCL fails if anything, including a symlink, is already there
+				flags |= os.O_EXCL
+			} else {
+				// Remove existing file if it exists
+				os.Remove(target)
+			}
 
 			// Create and write file
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
+			f, err := os.OpenFile(target, flags, mode)
+			if os.IsExist(err) {
+				return fmt.Errorf("refusing to overwrite existing file %s: %w", target, err)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to create file %s: %w", target, err)
 			}
 
-			n, err := io.Copy(f, tr)
+			n, err := io.Copy(f, &contextReader{ctx: ctx, r: tr})
+			if err == nil && opts.Sync {
+				if err := f.Sync(); err != nil {
+					f.Close()
+					return fmt.Errorf("failed to sync file %s: %w", target, err)
+				}
+			}
 			closeErr := f.Close()
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("failed to write file %s: %w: %w", target, ErrTruncatedArchive, err)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to write file %s: %w", target, err)
 			}
@@ -317,18 +709,20 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 			if header.Size > 0 && n != header.Size {
 				return fmt.Errorf("only wrote %d bytes to %s; expected %d", n, target, header.Size)
 			}
-
-			// Set permissions (in case umask modified them)
-			// Note: Permissions are already set when opening the file, this ensures umask didn't modify them
-			os.Chmod(target, mode)
+			touchedDirs[parentDir] = true
 
 			// Set ownership if requested (only on Linux, skipped on Windows)
 			// Note: We only chown if explicitly requested (uid/gid != 0) to avoid overhead on large archives
 			// Note: We don't validate uid/gid ranges - the OS will reject invalid values
-			if uid > 0 || gid > 0 {
+			if opts.chown() {
 				os.Chown(target, int(uid), int(gid)) // Error ignored for Windows compatibility
 			}
 
+			// Set permissions (in case umask modified them)
+			// Note: Permissions are already set when opening the file, this ensures umask didn't modify them.
+			// This runs after chown, which clears setuid/setgid bits.
+			os.Chmod(target, mode)
+
 			// Preserve mtime
 			if !header.ModTime.IsZero() {
 				os.Chtimes(target, header.ModTime, header.ModTime)
@@ -340,9 +734,86 @@ func ExtractTarStream(ctx context.Context, r io.Reader, extractDir string, uid,
 		}
 	}
 
+	// Apply directory modes and mtimes last, deepest first, so restrictive
+	// modes don't block writing children and writing children doesn't bump
+	// the directory's mtime.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			return fmt.Errorf("failed to set permissions on directory %s: %w", d.path, err)
+		}
+		if !d.modTime.IsZero() {
+			os.Chtimes(d.path, d.modTime, d.modTime)
+		}
+	}
+
+	if opts.Sync {
+		for dir := range touchedDirs {
+			if err := syncDir(dir); err != nil {
+				return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// trailerReader tracks where a tar stream read so far has zeroed 512-byte
+// blocks, so that the end-of-archive marker, which tar.Reader does not
+// require before reporting io.EOF, can be checked for.
+type trailerReader struct {
+	r   io.Reader
+	off int64
+	// zero is true while the current block has been all zeros so far.
+	zero bool
+	// zeroAt is the offset of the last complete block if it was all
+	// zeros, or -1.
+	zeroAt int64
+}
+
+func (t *trailerReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	data := p[:n]
+	for len(data) > 0 {
+		inBlock := int(t.off % 512)
+		if inBlock == 0 {
+			t.zero = true
+		}
+		take := min(512-inBlock, len(data))
+		if t.zero {
+			for _, b := range data[:take] {
+				if b != 0 {
+					t.zero = false
+					break
+				}
+			}
+		}
+		data = data[take:]
+		t.off += int64(take)
+		if t.off%512 == 0 {
+			t.zeroAt = -1
+			if t.zero {
+				t.zeroAt = t.off - 512
+			}
+		}
+	}
+	return n, err
+}
+
+// syncDir flushes a directory's entries to disk. Directories cannot be
+// synced on Windows, so it does nothing there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
 // ValidRelPath validates that a path is a valid relative path
 // and doesn't contain directory traversal attempts.
 // Note: Backslashes are allowed in filenames (e.g., systemd unit files with escaped characters).
@@ -357,15 +828,63 @@ func ValidRelPath(p string) bool {
 	return true
 }
 
+// ValidRelPathStrict is like ValidRelPath but also rejects names that can
+// escape the destination on Windows, where backslash is a separator: drive
+// letters such as "C:\evil" or "C:evil", UNC and rooted paths such as
+// "\\server\share" or "\evil", and any ".." component, including a
+// standalone or trailing one, whether separated by "/" or "\".
+func ValidRelPathStrict(p string) bool {
+	if !ValidRelPath(p) {
+		return false
+	}
+	// Rooted and UNC paths
+	if strings.HasPrefix(p, `\`) {
+		return false
+	}
+	// Drive letters, absolute or drive-relative
+	if len(p) >= 2 && p[1] == ':' && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z') {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
 // ExtractTarToPath extracts a tar stream to a local path with cp-like renaming.
 // If dest exists and is a directory, extracts into it. Otherwise extracts and renames.
 // No temporary directories are used - extraction happens directly.
 // If uid or gid are non-zero, files will be chowned to that uid/gid after creation.
 func ExtractTarToPath(ctx context.Context, r io.Reader, dest string, uid, gid uint32, excludePatterns ...string) error {
+	return ExtractTarToPathWithOptions(ctx, r, dest, ExtractTarOptions{
+		UID:             uid,
+		GID:             gid,
+		ExcludePatterns: excludePatterns,
+	})
+}
+
+// ExtractTarToPathWithOptions is like ExtractTarToPath but takes an
+// ExtractTarOptions.
+func ExtractTarToPathWithOptions(ctx context.Context, r io.Reader, dest string, opts ExtractTarOptions) error {
 	destInfo, err := os.Stat(dest)
 	destExists := err == nil
 	destIsDir := destExists && destInfo.IsDir()
 
+	if destExists && !destIsDir && opts.NoOverwrite {
+		return fmt.Errorf("refusing to overwrite existing file %s: %w", dest, os.ErrExist)
+	}
+
+	if opts.Staged && destIsDir {
+		if opts.NoOverwrite {
+			return fmt.Errorf("staged extraction cannot be combined with NoOverwrite")
+		}
+		if canRenameInto(filepath.Dir(dest), dest) {
+			return extractTarStaged(ctx, r, dest, destInfo.Mode(), opts)
+		}
+	}
+
 	var extractDir string
 	var topLevelName string
 
@@ -383,7 +902,7 @@ func ExtractTarToPath(ctx context.Context, r io.Reader, dest string, uid, gid ui
 	}
 
 	// Extract directly to extractDir
-	if err := ExtractTarStream(ctx, r, extractDir, uid, gid, excludePatterns...); err != nil {
+	if err := ExtractTarStreamWithOptions(ctx, r, extractDir, opts); err != nil {
 		return fmt.Errorf("failed to extract tar: %w", err)
 	}
 
@@ -417,7 +936,79 @@ func ExtractTarToPath(ctx context.Context, r io.Reader, dest string, uid, gid ui
 		if err := os.Rename(extractedPath, finalDest); err != nil {
 			return fmt.Errorf("failed to rename extracted content to destination: %w", err)
 		}
+		if opts.Sync {
+			if err := syncDir(filepath.Dir(finalDest)); err != nil {
+				return fmt.Errorf("failed to sync directory %s: %w", filepath.Dir(finalDest), err)
+			}
+		}
 	}
 
 	return nil
 }
+
+// extractTarStaged extracts into a temporary sibling of dest, then swaps it
+// in for dest, keeping dest intact if extraction fails.
+func extractTarStaged(ctx context.Context, r io.Reader, dest string, mode os.FileMode, opts ExtractTarOptions) error {
+	parent, base := filepath.Dir(dest), filepath.Base(dest)
+
+	staging, err := os.MkdirTemp(parent, "."+base+".staging-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := os.Chmod(staging, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to set staging directory mode: %w", err)
+	}
+
+	opts.Staged = false
+	if err := ExtractTarStreamWithOptions(ctx, r, staging, opts); err != nil {
+		return fmt.Errorf("failed to extract tar: %w", err)
+	}
+
+	old, err := os.MkdirTemp(parent, "."+base+".old-")
+	if err != nil {
+		return fmt.Errorf("failed to reserve backup name: %w", err)
+	}
+	if err := os.Remove(old); err != nil {
+		return fmt.Errorf("failed to reserve backup name: %w", err)
+	}
+
+	if err := os.Rename(dest, old); err != nil {
+		return fmt.Errorf("failed to move destination aside: %w", err)
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		if rerr := os.Rename(old, dest); rerr != nil {
+			return fmt.Errorf("failed to swap in extracted tree: %w (previous tree left at %s)", err, old)
+		}
+		return fmt.Errorf("failed to swap in extracted tree: %w", err)
+	}
+
+	if opts.Sync {
+		if err := syncDir(parent); err != nil {
+			return fmt.Errorf("failed to sync directory %s: %w", parent, err)
+		}
+	}
+
+	if err := os.RemoveAll(old); err != nil {
+		return fmt.Errorf("failed to remove previous tree at %s: %w", old, err)
+	}
+	return nil
+}
+
+// canRenameInto reports whether entries can be renamed from dir into
+// target, i.e. both are on the same filesystem, by moving an empty probe
+// directory across.
+func canRenameInto(dir, target string) bool {
+	probe, err := os.MkdirTemp(dir, ".slicer-probe-")
+	if err != nil {
+		return false
+	}
+	moved := filepath.Join(target, filepath.Base(probe))
+	if err := os.Rename(probe, moved); err != nil {
+		os.Remove(probe)
+		return false
+	}
+	os.Remove(moved)
+	return true
+}
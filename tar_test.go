@@ -4,11 +4,14 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNormalizeExcludePatterns(t *testing.T) {
@@ -167,3 +170,603 @@ func collectTarEntryNames(t *testing.T, data []byte) map[string]struct{} {
 		names[header.Name] = struct{}{}
 	}
 }
+
+func TestExtractTarStream_RestoresRestrictiveDirModes(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []*tar.Header{
+		{Name: "home/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "home/.ssh/", Typeflag: tar.TypeDir, Mode: 0o700},
+		{Name: "home/.ssh/config", Typeflag: tar.TypeReg, Mode: 0o600, Size: 4},
+	}
+	for _, h := range entries {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if h.Size > 0 {
+			if _, err := tw.Write([]byte("Host")); err != nil {
+				t.Fatalf("failed to write body: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if err := ExtractTarStream(context.Background(), &buf, destDir, 0, 0); err != nil {
+		t.Fatalf("ExtractTarStream() error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(destDir, "home", ".ssh"))
+	if err != nil {
+		t.Fatalf("expected .ssh to exist: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o700 {
+		t.Fatalf("Want .ssh mode 0700, got %#o", got)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "home", ".ssh", "config")); err != nil {
+		t.Fatalf("expected config to exist: %v", err)
+	}
+}
+
+func TestExtractTarStream_NoOverwrite(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "file.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 3}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := tw.Write([]byte("new")); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+
+	destDir := t.TempDir()
+	existing := filepath.Join(destDir, "file.txt")
+	if err := os.WriteFile(existing, []byte("old"), 0o644); err != nil {
+		t.Fatalf("failed to write existing file: %v", err)
+	}
+
+	err := ExtractTarStreamWithOptions(context.Background(), &buf, destDir, ExtractTarOptions{NoOverwrite: true})
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Want os.ErrExist, got %v", err)
+	}
+
+	data, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatalf("failed to read existing file: %v", err)
+	}
+	if string(data) != "old" {
+		t.Fatalf("Want existing file untouched, got %q", data)
+	}
+}
+
+func TestExtractTarStream_SkipUnchanged(t *testing.T) {
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"same.txt", "changed.txt"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o600, Size: 3, ModTime: modTime}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte("new")); err != nil {
+			t.Fatalf("failed to write body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+
+	destDir := t.TempDir()
+	same := filepath.Join(destDir, "same.txt")
+	changed := filepath.Join(destDir, "changed.txt")
+	if err := os.WriteFile(same, []byte("old"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Chtimes(same, modTime, modTime); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+	if err := os.WriteFile(changed, []byte("old"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := ExtractTarStreamWithOptions(context.Background(), &buf, destDir, ExtractTarOptions{SkipUnchanged: true}); err != nil {
+		t.Fatalf("ExtractTarStreamWithOptions() error = %v", err)
+	}
+
+	if data, _ := os.ReadFile(same); string(data) != "old" {
+		t.Fatalf("Want unchanged file skipped, got %q", data)
+	}
+	if info, err := os.Stat(same); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("Want skipped file mode reconciled to 0600, got %v (err %v)", info.Mode().Perm(), err)
+	}
+	if data, _ := os.ReadFile(changed); string(data) != "new" {
+		t.Fatalf("Want changed file rewritten, got %q", data)
+	}
+}
+
+func TestStreamTarArchiveWithOptions_PreserveModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+		t.Fatalf("failed to create source dir: %v", err)
+	}
+	script := filepath.Join(sourceDir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Chmod(script, 0o740); err != nil {
+		t.Fatalf("failed to chmod file: %v", err)
+	}
+
+	modeOf := func(opts StreamTarOptions) int64 {
+		var buf bytes.Buffer
+		if err := StreamTarArchiveWithOptions(context.Background(), &buf, tmpDir, "source", opts); err != nil {
+			t.Fatalf("StreamTarArchiveWithOptions() error = %v", err)
+		}
+		tr := tar.NewReader(&buf)
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("failed to read header: %v", err)
+		}
+		return header.Mode
+	}
+
+	if got := modeOf(StreamTarOptions{}); got != 0o751 {
+		t.Fatalf("Want default mode 0751, got %o", got)
+	}
+	if got := modeOf(StreamTarOptions{PreserveModes: true}); got != 0o740 {
+		t.Fatalf("Want preserved mode 0740, got %o", got)
+	}
+}
+
+// cancelAfterWriter cancels a context once n bytes have been written.
+type cancelAfterWriter struct {
+	n       int
+	written int
+	cancel  context.CancelFunc
+}
+
+func (w *cancelAfterWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	if w.written >= w.n {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+func TestStreamTarArchive_CancelInterruptsLargeFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+		t.Fatalf("failed to create source dir: %v", err)
+	}
+	const size = 16 << 20
+	if err := os.WriteFile(filepath.Join(sourceDir, "big.bin"), make([]byte, size), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelAfterWriter{n: 1 << 20, cancel: cancel}
+
+	err := StreamTarArchive(ctx, w, tmpDir, "source")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Want context.Canceled, got %v", err)
+	}
+	if w.written >= size {
+		t.Fatalf("Want copy interrupted mid-file, but %d bytes were written", w.written)
+	}
+}
+
+func TestStreamTarArchiveWithOptions_FollowSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	secretsDir := filepath.Join(tmpDir, "secrets")
+	for _, dir := range []string{sourceDir, filepath.Join(secretsDir, "tls")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(secretsDir, "token"), []byte("t0k3n"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(secretsDir, "tls", "cert.pem"), []byte("cert"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	links := map[string]string{
+		"token":    filepath.Join(secretsDir, "token"),
+		"secrets":  secretsDir,
+		"loop":     ".",
+		"dangling": filepath.Join(tmpDir, "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(sourceDir, name)); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+	// A link back up from inside a followed directory must not recurse.
+	if err := os.Symlink(secretsDir, filepath.Join(secretsDir, "tls", "up")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	names := func(opts StreamTarOptions) []string {
+		var buf bytes.Buffer
+		if err := StreamTarArchiveWithOptions(context.Background(), &buf, tmpDir, "source", opts); err != nil {
+			t.Fatalf("StreamTarArchiveWithOptions() error = %v", err)
+		}
+		var got []string
+		tr := tar.NewReader(&buf)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return got
+			}
+			if err != nil {
+				t.Fatalf("failed to read header: %v", err)
+			}
+			got = append(got, header.Name)
+		}
+	}
+
+	if got := names(StreamTarOptions{}); len(got) != 0 {
+		t.Fatalf("Want symlinks skipped by default, got %v", got)
+	}
+
+	want := []string{"secrets/", "secrets/tls/", "secrets/tls/cert.pem", "secrets/token", "token"}
+	if got := names(StreamTarOptions{FollowSymlinks: true}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Want %v, got %v", want, got)
+	}
+
+	skipped := func(opts StreamTarOptions) []string {
+		var got []string
+		opts.OnSkipSpecial = func(relPath string, mode os.FileMode) {
+			if mode&os.ModeSymlink == 0 {
+				t.Errorf("Want %s reported as a symlink, got mode %v", relPath, mode)
+			}
+			got = append(got, relPath)
+		}
+		names(opts)
+		return got
+	}
+
+	wantSkipped := []string{"dangling", "loop", "secrets", "token"}
+	if got := skipped(StreamTarOptions{}); !reflect.DeepEqual(got, wantSkipped) {
+		t.Fatalf("Want skipped %v, got %v", wantSkipped, got)
+	}
+	wantSkipped = []string{"dangling", "loop", "secrets/tls/up"}
+	if got := skipped(StreamTarOptions{FollowSymlinks: true}); !reflect.DeepEqual(got, wantSkipped) {
+		t.Fatalf("Want skipped %v, got %v", wantSkipped, got)
+	}
+	if got := skipped(StreamTarOptions{ExcludePatterns: []string{"dangling", "loop"}}); !reflect.DeepEqual(got, []string{"secrets", "token"}) {
+		t.Fatalf("Want excluded links not reported, got %v", got)
+	}
+}
+
+func TestStreamTarEntries_SkipUnreadable(t *testing.T) {
+	tmpDir := t.TempDir()
+	good := filepath.Join(tmpDir, "good.txt")
+	if err := os.WriteFile(good, []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that disappears between the walk and the write cannot be opened.
+	entries := []tarEntry{
+		{path: filepath.Join(tmpDir, "gone.txt"), relPath: "gone.txt", info: info},
+		{path: good, relPath: "good.txt", info: info},
+	}
+
+	var buf bytes.Buffer
+	if err := streamTarEntries(context.Background(), &buf, entries, StreamTarOptions{}); err == nil {
+		t.Fatal("Want error for unreadable file by default")
+	}
+
+	var skipped []string
+	buf.Reset()
+	err = streamTarEntries(context.Background(), &buf, entries, StreamTarOptions{
+		SkipUnreadable: true,
+		OnSkip: func(relPath string, err error) {
+			skipped = append(skipped, relPath)
+		},
+	})
+	if err != nil {
+		t.Fatalf("streamTarEntries() error = %v", err)
+	}
+	if !reflect.DeepEqual(skipped, []string{"gone.txt"}) {
+		t.Fatalf("Want gone.txt reported as skipped, got %v", skipped)
+	}
+
+	tr := tar.NewReader(&buf)
+	header, err := tr.Next()
+	if err != nil || header.Name != "good.txt" {
+		t.Fatalf("Want good.txt in archive, got %v, %v", header, err)
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Want only good.txt in archive, got %v", err)
+	}
+}
+
+func TestStreamTarArchive_SkipUnreadableDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	tmpDir := t.TempDir()
+	locked := filepath.Join(tmpDir, "source", "locked")
+	if err := os.MkdirAll(locked, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "source", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(locked, 0o755) })
+
+	if err := StreamTarArchive(context.Background(), io.Discard, tmpDir, "source"); err == nil {
+		t.Fatal("Want error for unreadable directory by default")
+	}
+
+	var skipped []string
+	err := StreamTarArchiveWithOptions(context.Background(), io.Discard, tmpDir, "source", StreamTarOptions{
+		SkipUnreadable: true,
+		OnSkip:         func(relPath string, err error) { skipped = append(skipped, relPath) },
+	})
+	if err != nil {
+		t.Fatalf("StreamTarArchiveWithOptions() error = %v", err)
+	}
+	if !reflect.DeepEqual(skipped, []string{"locked"}) {
+		t.Fatalf("Want locked reported as skipped, got %v", skipped)
+	}
+}
+
+func TestValidRelPathStrict(t *testing.T) {
+	tests := []struct {
+		path  string
+		loose bool
+		want  bool
+	}{
+		{path: "etc/passwd", loose: true, want: true},
+		{path: `systemd/dev-disk-by\x2duuid.mount`, loose: true, want: true},
+		{path: "a..b/c", loose: true, want: true},
+		{path: `C:\evil`, loose: true},
+		{path: "c:evil", loose: true},
+		{path: `\\server\share\evil`, loose: true},
+		{path: `\evil`, loose: true},
+		{path: `a\..\..\evil`, loose: true},
+		{path: "..", loose: true},
+		{path: "a/..", loose: true},
+		{path: "../evil"},
+		{path: "/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := ValidRelPath(tt.path); got != tt.loose {
+				t.Fatalf("ValidRelPath(%q) = %v, want %v", tt.path, got, tt.loose)
+			}
+			if got := ValidRelPathStrict(tt.path); got != tt.want {
+				t.Fatalf("ValidRelPathStrict(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTarStream_StrictPathsRejectsWindowsNames(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: `C:\Windows\evil.dll`, Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := tw.Write([]byte("x")); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+
+	err := ExtractTarStreamWithOptions(context.Background(), &buf, t.TempDir(), ExtractTarOptions{StrictPaths: true})
+	if err == nil || !strings.Contains(err.Error(), "invalid name") {
+		t.Fatalf("Want invalid name error, got %v", err)
+	}
+}
+
+func TestExtractTarStream_PreserveSpecialBits(t *testing.T) {
+	archive := func() *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "tmp/", Mode: 0o1777, Typeflag: tar.TypeDir}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "tmp/sudo", Mode: 0o4750, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatalf("failed to write body: %v", err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("failed to close tar: %v", err)
+		}
+		return &buf
+	}
+
+	modes := func(opts ExtractTarOptions) (dir, file os.FileMode) {
+		dest := t.TempDir()
+		if err := ExtractTarStreamWithOptions(context.Background(), archive(), dest, opts); err != nil {
+			t.Fatalf("ExtractTarStreamWithOptions() error = %v", err)
+		}
+		di, err := os.Stat(filepath.Join(dest, "tmp"))
+		if err != nil {
+			t.Fatalf("failed to stat dir: %v", err)
+		}
+		fi, err := os.Stat(filepath.Join(dest, "tmp", "sudo"))
+		if err != nil {
+			t.Fatalf("failed to stat file: %v", err)
+		}
+		return di.Mode() & tarModeMask, fi.Mode() & tarModeMask
+	}
+
+	if dir, file := modes(ExtractTarOptions{}); dir != 0o777 || file != 0o751 {
+		t.Fatalf("Want normalized modes 0777 and 0751, got %v and %v", dir, file)
+	}
+
+	dir, file := modes(ExtractTarOptions{PreserveSpecialBits: true})
+	if dir != os.ModeSticky|0o777 {
+		t.Fatalf("Want sticky 0777 dir, got %v", dir)
+	}
+	if file != os.ModeSetuid|0o750 {
+		t.Fatalf("Want setuid 0750 file, got %v", file)
+	}
+}
+
+func TestExtractTarToPath_StagedKeepsTreeOnFailure(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "conf")
+	if err := os.Mkdir(dest, 0o750); err != nil {
+		t.Fatalf("failed to create dest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "old.conf"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "new.conf", Mode: 0o644, Size: 3, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := tw.Write([]byte("new")); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	archive := buf.Bytes()
+
+	// A truncated archive fails part way and must leave the old tree alone.
+	truncated := bytes.NewReader(archive[:513])
+	if err := ExtractTarToPathWithOptions(context.Background(), truncated, dest, ExtractTarOptions{Staged: true}); err == nil {
+		t.Fatal("Want error for a truncated archive")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "old.conf")); err != nil {
+		t.Fatalf("Want old tree intact, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "new.conf")); !os.IsNotExist(err) {
+		t.Fatalf("Want no partial file in dest, got %v", err)
+	}
+
+	if err := ExtractTarToPathWithOptions(context.Background(), bytes.NewReader(archive), dest, ExtractTarOptions{Staged: true}); err != nil {
+		t.Fatalf("ExtractTarToPathWithOptions() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "old.conf")); !os.IsNotExist(err) {
+		t.Fatalf("Want old tree replaced, got %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dest, "new.conf")); err != nil || string(got) != "new" {
+		t.Fatalf("Want new.conf extracted, got %q, %v", got, err)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0o750 {
+		t.Fatalf("Want dest mode 0750 kept, got %v, %v", info, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Fatalf("Want staging and backup directories removed, got %d entries", len(entries))
+	}
+}
+
+func TestExtractTarToPath_Sync(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "etc/", Mode: 0o755, Typeflag: tar.TypeDir}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "etc/app.conf", Mode: 0o644, Size: 2, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := tw.Write([]byte("ok")); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	archive := buf.Bytes()
+
+	for _, staged := range []bool{false, true} {
+		dest := t.TempDir()
+		opts := ExtractTarOptions{Sync: true, Staged: staged}
+		if err := ExtractTarToPathWithOptions(context.Background(), bytes.NewReader(archive), dest, opts); err != nil {
+			t.Fatalf("ExtractTarToPathWithOptions(staged=%v) error = %v", staged, err)
+		}
+		if got, err := os.ReadFile(filepath.Join(dest, "etc", "app.conf")); err != nil || string(got) != "ok" {
+			t.Fatalf("Want app.conf extracted with staged=%v, got %q, %v", staged, got, err)
+		}
+	}
+}
+
+func TestExtractTarStream_DetectsTruncation(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatalf("failed to write body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	archive := buf.Bytes()
+
+	extract := func(data []byte, opts ExtractTarOptions) error {
+		return ExtractTarStreamWithOptions(context.Background(), bytes.NewReader(data), t.TempDir(), opts)
+	}
+
+	if err := extract(archive, ExtractTarOptions{}); err != nil {
+		t.Fatalf("Want complete archive extracted, got %v", err)
+	}
+
+	// Cut after the first entry, on a block boundary: tar.Reader sees a
+	// clean io.EOF, but b.txt is missing.
+	betweenEntries := archive[:1024]
+	if err := extract(betweenEntries, ExtractTarOptions{}); !errors.Is(err, ErrTruncatedArchive) {
+		t.Fatalf("Want ErrTruncatedArchive between entries, got %v", err)
+	}
+	if err := extract(betweenEntries, ExtractTarOptions{AllowMissingTrailer: true}); err != nil {
+		t.Fatalf("Want missing trailer allowed, got %v", err)
+	}
+
+	if err := extract(archive[:700], ExtractTarOptions{}); !errors.Is(err, ErrTruncatedArchive) {
+		t.Fatalf("Want ErrTruncatedArchive inside an entry, got %v", err)
+	}
+	if err := extract(nil, ExtractTarOptions{}); !errors.Is(err, ErrTruncatedArchive) {
+		t.Fatalf("Want ErrTruncatedArchive for an empty stream, got %v", err)
+	}
+
+	// A zero-filled file ends in an all-zero data block, which must not be
+	// mistaken for the end-of-archive marker when the stream is cut there.
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "disk.img", Mode: 0o644, Size: 512, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := tw.Write(make([]byte, 512)); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	zeros := buf.Bytes()
+	if err := extract(zeros, ExtractTarOptions{}); err != nil {
+		t.Fatalf("Want complete archive of a zero-filled file extracted, got %v", err)
+	}
+	if err := extract(zeros[:1024], ExtractTarOptions{}); !errors.Is(err, ErrTruncatedArchive) {
+		t.Fatalf("Want ErrTruncatedArchive after a zero-filled file, got %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package slicer
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 	"net"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,8 +26,78 @@ type SlicerNode struct {
 	Tags       []string  `json:"tags,omitempty"`
 	Status     string    `json:"status,omitempty"` // "Running", "Paused", or "Stopped"
 	Persistent bool      `json:"persistent,omitempty"`
+	DiskImage  string    `json:"disk_image,omitempty"` // Disk image of a persistent VM; not reported by older servers
+	HostNode   string    `json:"host_node,omitempty"`  // Host the VM runs on; not reported by older servers
+	Secrets    []string  `json:"secrets,omitempty"`    // Names of secrets mounted in the VM; not reported by older servers
+
+	// Metadata holds key/value labels such as owner or cost-center. Not
+	// reported by older servers.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Gateway is the VM's default gateway. Not reported by older servers.
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// IPAddress returns the VM's IP address, without the mask if IP is in CIDR
+// notation, or nil if it cannot be parsed.
+func (n *SlicerNode) IPAddress() net.IP {
+	ip, _ := parseNodeCIDR(n.IP)
+	return ip
+}
+
+// Network returns the VM's subnet when IP is in CIDR notation, or nil when
+// the server reported a bare address.
+func (n *SlicerNode) Network() *net.IPNet {
+	_, network := parseNodeCIDR(n.IP)
+	return network
+}
+
+// GatewayAddress returns the parsed Gateway, or nil if the server did not
+// report one.
+func (n *SlicerNode) GatewayAddress() net.IP {
+	return net.ParseIP(n.Gateway)
+}
+
+// Age returns how long ago the node was created, or zero if the server did
+// not report CreatedAt.
+func (n *SlicerNode) Age() time.Duration {
+	if n.CreatedAt.IsZero() {
+		return 0
+	}
+	return time.Since(n.CreatedAt)
 }
 
+// SortNodesByAge sorts nodes oldest first, e.g. to pick the oldest VMs for
+// cleanup. Nodes without CreatedAt sort last; ties are ordered by hostname.
+func SortNodesByAge(nodes []SlicerNode) {
+	slices.SortStableFunc(nodes, func(a, b SlicerNode) int {
+		switch {
+		case a.CreatedAt.IsZero() != b.CreatedAt.IsZero():
+			if a.CreatedAt.IsZero() {
+				return 1
+			}
+			return -1
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+		return strings.Compare(a.Hostname, b.Hostname)
+	})
+}
+
+// SortNodesByHostname sorts nodes by hostname.
+func SortNodesByHostname(nodes []SlicerNode) {
+	slices.SortStableFunc(nodes, func(a, b SlicerNode) int {
+		return strings.Compare(a.Hostname, b.Hostname)
+	})
+}
+
+// Values reported in SlicerNode.Status. Older servers leave Status empty.
+const (
+	NodeStatusRunning = "Running"
+	NodeStatusPaused  = "Paused"
+	NodeStatusStopped = "Stopped"
+)
+
 // SlicerCreateNodeRequest contains parameters for creating a node
 type SlicerCreateNodeRequest struct {
 	RamBytes   int64                          `json:"ram_bytes,omitempty"` // RAM size in bytes (must not exceed host group limit)
@@ -30,13 +105,79 @@ type SlicerCreateNodeRequest struct {
 	GPUCount   int                            `json:"gpu_count,omitempty"`
 	Persistent bool                           `json:"persistent,omitempty"`
 	DiskImage  string                         `json:"disk_image,omitempty"`
+	DiskSizeGB int                            `json:"disk_size_gb,omitempty"` // Root disk size in GB; zero keeps the image default
 	ImportUser string                         `json:"import_user,omitempty"`
 	SSHKeys    []string                       `json:"ssh_keys,omitempty"`
 	Userdata   string                         `json:"userdata,omitempty"`
-	IP         string                         `json:"ip,omitempty"`
+	IP         string                         `json:"ip,omitempty"` // Static IP, plain or CIDR, checked before the request is sent
 	Tags       []string                       `json:"tags,omitempty"`
 	Secrets    []string                       `json:"secrets,omitempty"`
 	Network    *SlicerCreateNodeNetworkPolicy `json:"network,omitempty"`
+	Metadata   map[string]string              `json:"metadata,omitempty"` // Key/value labels, see SetVMMetadata
+
+	// HostNode asks for the VM to be placed on the named host within the
+	// host group, for GPU or latency-sensitive workloads. A host group is
+	// a pool of VMs that may span several physical hosts; leaving HostNode
+	// empty lets the server choose one. The create fails with an error
+	// wrapping ErrNotFound if the group has no such host, or with an
+	// APIError if the host has no capacity left.
+	HostNode string `json:"host_node,omitempty"`
+
+	// TTL asks the server to delete the VM once it has existed this long,
+	// so leaked VMs (e.g. from CI runs) do not accumulate. It is sent as a
+	// duration string such as "1h30m". If GetCapabilities has been called
+	// and the server does not report Capabilities.TTL, the create is
+	// refused with ErrNotSupported; use ExpireVMAfter for those servers.
+	TTL time.Duration `json:"-"`
+}
+
+// validateCreateIP rejects a static IP that is neither a plain address nor
+// an address in CIDR form, so a typo fails before the request is sent.
+func validateCreateIP(ip string) error {
+	if ip == "" {
+		return nil
+	}
+	if strings.Contains(ip, "/") {
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			return fmt.Errorf("invalid IP %q: not a valid CIDR, e.g. 192.168.137.2/24", ip)
+		}
+		return nil
+	}
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid IP %q: not a valid address, e.g. 192.168.137.2", ip)
+	}
+	return nil
+}
+
+// MarshalJSON encodes TTL as a duration string under "ttl".
+func (r SlicerCreateNodeRequest) MarshalJSON() ([]byte, error) {
+	type plain SlicerCreateNodeRequest
+	out := struct {
+		plain
+		TTL string `json:"ttl,omitempty"`
+	}{plain: plain(r)}
+	if r.TTL > 0 {
+		out.TTL = formatDuration(r.TTL)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON accepts "ttl" as a duration string or integer nanoseconds.
+func (r *SlicerCreateNodeRequest) UnmarshalJSON(data []byte) error {
+	type plain SlicerCreateNodeRequest
+	in := struct {
+		*plain
+		TTL json.RawMessage `json:"ttl,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	ttl, err := parseJSONDuration(in.TTL)
+	if err != nil {
+		return fmt.Errorf("ttl: %w", err)
+	}
+	r.TTL = ttl
+	return nil
 }
 
 // SlicerCreateNodeNetworkPolicy optionally overrides the host group's
@@ -62,6 +203,9 @@ type SlicerCreateNodeOptions struct {
 	Wait SlicerCreateNodeWaitFor `json:"-"`
 	// Timeout is optional wait timeout when Wait is set. Parsed as Go duration.
 	Timeout time.Duration `json:"-"`
+	// ValidateUserdata checks the request's Userdata with ValidateUserdata
+	// before anything is sent, failing the call on a malformed document.
+	ValidateUserdata bool `json:"-"`
 }
 
 // SlicerRestoreVMWaitFor controls server-side readiness waiting for restore.
@@ -102,14 +246,38 @@ type SlicerCreateNodeResponse struct {
 	IP        string    `json:"ip"`
 	CreatedAt time.Time `json:"created_at"`
 	Arch      string    `json:"arch,omitempty"`
+	Gateway   string    `json:"gateway,omitempty"` // Default gateway; not reported by older servers
 }
 
 func (n *SlicerCreateNodeResponse) IPAddress() net.IP {
-	if strings.Contains(n.IP, "/") {
-		ip, _, _ := net.ParseCIDR(n.IP)
-		return ip
+	ip, _ := parseNodeCIDR(n.IP)
+	return ip
+}
+
+// Network returns the VM's subnet when IP is in CIDR notation, e.g.
+// 192.168.137.0/24 for "192.168.137.2/24", or nil for a bare address.
+func (n *SlicerCreateNodeResponse) Network() *net.IPNet {
+	_, network := parseNodeCIDR(n.IP)
+	return network
+}
+
+// GatewayAddress returns the parsed Gateway, or nil if the server did not
+// report one.
+func (n *SlicerCreateNodeResponse) GatewayAddress() net.IP {
+	return net.ParseIP(n.Gateway)
+}
+
+// parseNodeCIDR parses an address as reported for a VM, with or without a
+// mask, into the address and, if a mask was given, its subnet.
+func parseNodeCIDR(s string) (net.IP, *net.IPNet) {
+	if strings.Contains(s, "/") {
+		ip, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, nil
+		}
+		return ip, network
 	}
-	return net.ParseIP(n.IP)
+	return net.ParseIP(s), nil
 }
 
 // SlicerHostGroup represents a host group from the /hostgroup endpoint.
@@ -143,8 +311,15 @@ type SlicerExecWriteResult struct {
 	DroppedBytes  int64  `json:"dropped_bytes,omitempty"`
 	DroppedFrames int    `json:"dropped_frames,omitempty"`
 	Message       string `json:"message,omitempty"`
+
+	// readErr is the error behind a final frame sent because the response
+	// could not be read, for ExecWithReconnect.
+	readErr error
 }
 
+// ExecResult is the overall outcome of a command run to completion, as
+// returned by blocking helpers such as ExecBuffered. Streaming callers
+// receive per-frame SlicerExecWriteResult values instead.
 type ExecResult struct {
 	Stdout    string    `json:"stdout,omitempty"`
 	Stderr    string    `json:"stderr,omitempty"`
@@ -155,10 +330,27 @@ type ExecResult struct {
 	Signal    string    `json:"signal,omitempty"`
 	ExitCode  int       `json:"exit_code"`
 	Error     string    `json:"error,omitempty"`
+
+	// Err is non-nil when the command failed: an *ExitError for a non-zero
+	// exit code, or an error carrying the server-reported Error message.
+	Err error `json:"-"`
+
+	// Duration is how long the command ran. It is taken from StartedAt and
+	// EndedAt when the server reports both, otherwise from the client's
+	// round-trip time.
+	Duration time.Duration `json:"-"`
 }
 
 // SlicerExecRequest contains parameters for invoking a command
 // within a VM.
+//
+// Without a Shell, Command is run directly with Args as its argument
+// vector, so no quoting, globbing or variable expansion happens. With a
+// Shell, the agent runs Command and Args through that shell with -c, so
+// Command may be a whole script line such as "make && make test"; Args are
+// appended to it. LoginShell additionally starts the shell as a login
+// shell, sourcing the profile so PATH and similar variables are set as in
+// an SSH session.
 type SlicerExecRequest struct {
 	Command     string   `json:"command,omitempty"`
 	Args        []string `json:"args,omitempty"`
@@ -172,14 +364,136 @@ type SlicerExecRequest struct {
 	Shell       string   `json:"shell,omitempty"`
 	Cwd         string   `json:"cwd,omitempty"`
 	Permissions string   `json:"permissions,omitempty"`
+
+	// MergeStderr delivers stderr as stdout, like 2>&1. The agent is asked
+	// to merge the streams and the SDK also rewrites any stderr frames it
+	// still receives as stdout. Streamed output keeps the order in which
+	// frames arrive from the agent; output inside one frame that carries
+	// both streams is placed stdout first. ExecBuffered can only append
+	// stderr after stdout unless the agent merges the streams itself.
+	MergeStderr bool `json:"merge_stderr,omitempty"`
+
+	// Extra holds additional query parameters sent to the exec endpoint,
+	// for agent features the SDK does not model yet, such as a nice level.
+	// Keys must not collide with the parameters the SDK sets itself, e.g.
+	// cmd, args or uid; such requests are rejected.
+	Extra map[string]string `json:"extra,omitempty"`
+
+	// LoginShell runs the command through a login shell (-l), for commands
+	// that depend on the login environment. When Shell is empty,
+	// DefaultLoginShell is used.
+	LoginShell bool `json:"login_shell,omitempty"`
 }
 
+// DefaultLoginShell is the shell used for SlicerExecRequest.LoginShell when
+// no Shell is set.
+const DefaultLoginShell = "/bin/sh"
+
 // SlicerCpRequest contains parameters for copying files to/from a VM
 type SlicerCpRequest struct {
 	VM   string // VM name
 	Path string // Path on the VM
 }
 
+// CpToVMOptions contains parameters for copying files to a VM.
+type CpToVMOptions struct {
+	// UID and GID set the ownership of the copied files. Zero means the
+	// default, NonRootUser lets the agent pick the non-root user.
+	UID uint32
+	GID uint32
+	// Permissions overrides the mode of the uploaded file in binary mode,
+	// in any form accepted by ParsePermissions.
+	Permissions string
+	// Mode is "tar" or "binary".
+	Mode string
+	// ExcludePatterns are glob patterns of paths to skip in tar mode.
+	ExcludePatterns []string
+	// PreserveModes keeps each file's local permission bits. In binary mode
+	// the source file's mode is sent when Permissions is empty; in tar mode
+	// the exact permission bits are archived instead of only widening the
+	// executable bit. Setuid, setgid and sticky bits are always dropped.
+	PreserveModes bool
+	// Concurrency, when greater than one, splits a directory copied in tar
+	// mode into that many tar streams uploaded in parallel. This speeds up
+	// trees of many small files; the default single stream is used for
+	// files and when Concurrency is zero or one.
+	Concurrency int
+	// FollowSymlinks archives what symlinks point to in tar mode, like
+	// tar -h, instead of skipping them.
+	FollowSymlinks bool
+	// SkipUnreadable leaves out files and directories that cannot be read
+	// in tar mode instead of failing the copy, reporting each to OnSkip.
+	SkipUnreadable bool
+	// OnSkip is called with the relative path and error of each entry left
+	// out by SkipUnreadable. It may be called from several goroutines when
+	// Concurrency is greater than one.
+	OnSkip func(relPath string, err error)
+	// OnSkipSpecial is called with the relative path and mode of each
+	// symlink, device, FIFO or socket left out in tar mode, e.g. to warn
+	// "skipped 3 symlinks". See StreamTarOptions.OnSkipSpecial.
+	OnSkipSpecial func(relPath string, mode os.FileMode)
+	// Archiver selects the archive format used in tar mode. Nil means
+	// TarArchiver. Concurrency is only honoured for TarArchiver; other
+	// formats are sent as a single stream.
+	Archiver Archiver
+	// CreateParents asks the agent to create missing parent directories of
+	// vmPath, like mkdir -p, owned by UID and GID. The copy fails with an
+	// error wrapping ErrNotSupported if capabilities fetched earlier with
+	// GetCapabilities report that the agent cannot, see
+	// Capabilities.CpCreateParents.
+	CreateParents bool
+	// ParentPermissions is the mode of directories made by CreateParents,
+	// in any form accepted by ParsePermissions. Empty means the agent's
+	// default, usually 0755.
+	ParentPermissions string
+	// Progress, if set, is called as a binary upload is sent with the bytes
+	// written so far and the total size. total is -1 when the size is not
+	// known in advance, as for CpReaderToVM with a size of -1. Retried
+	// uploads start again from zero.
+	Progress func(written, total int64)
+}
+
+// CpFromVMOptions contains parameters for copying files from a VM.
+type CpFromVMOptions struct {
+	// Permissions overrides the mode of the local file in binary mode, in
+	// any form accepted by ParsePermissions. Setuid, setgid and sticky
+	// bits are dropped, as are those in the mode reported by the VM.
+	Permissions string
+	// Mode is "tar" or "binary".
+	Mode string
+	// UID and GID set the local ownership of extracted entries in tar
+	// mode, e.g. a service account when running as root. If both are 0,
+	// the current user's UID/GID is used, so 0:0 cannot be requested
+	// explicitly; as root, the default is already 0:0. Ignored on Windows.
+	UID uint32
+	GID uint32
+	// NoChown skips ownership changes of extracted entries entirely in tar
+	// mode, regardless of UID/GID, e.g. in rootless containers where chown
+	// always fails. See ExtractTarOptions.NoChown.
+	NoChown bool
+	// ExcludePatterns are glob patterns of paths to skip in tar mode.
+	ExcludePatterns []string
+	// NoOverwrite returns an error wrapping os.ErrExist instead of
+	// replacing local files that already exist.
+	NoOverwrite bool
+	// SkipUnchanged leaves local files whose size and mtime already match
+	// in place in tar mode, for incremental syncs.
+	SkipUnchanged bool
+	// StrictPaths rejects tar entry names that could escape the destination
+	// on Windows, see ValidRelPathStrict. Always on when running on Windows.
+	StrictPaths bool
+	// Staged extracts into a temporary directory and swaps it in for an
+	// existing local directory only on success, in tar mode. See
+	// ExtractTarOptions.Staged.
+	Staged bool
+	// Archiver selects the archive format requested in tar mode. Nil means
+	// TarArchiver.
+	Archiver Archiver
+	// Sync flushes extracted files and directories to disk before
+	// returning, in tar mode. See ExtractTarOptions.Sync.
+	Sync bool
+}
+
 // SlicerFSInfo represents file system entry metadata returned by VM fs endpoints.
 type SlicerFSInfo struct {
 	Name  string    `json:"name"`
@@ -203,6 +517,11 @@ type SlicerNodeStat struct {
 	CreatedAt time.Time       `json:"created_at"`
 	Snapshot  *SlicerSnapshot `json:"snapshot"`
 	Error     string          `json:"error"`
+
+	// Stale is set by GetVMStats and StreamVMStats when
+	// ListOptions.MaxStatAge is given and
+	// Snapshot is missing or older than it. It is not part of the API.
+	Stale bool `json:"-"`
 }
 
 // SlicerSnapshot represents a snapshot of VM metrics
@@ -231,6 +550,33 @@ type SlicerSnapshot struct {
 	DiskSpaceUsed        uint64    `json:"diskSpaceUsed"`
 	DiskSpaceFree        uint64    `json:"diskSpaceFree"`
 	DiskSpaceUsedPercent float64   `json:"diskSpaceUsedPercent"`
+
+	// GPU metrics are only reported by agents on VMs with GPUs attached.
+	// GPUUtilization and the memory totals aggregate across all devices.
+	GPUUtilization float64         `json:"gpuUtilization,omitempty"`
+	GPUMemoryUsed  uint64          `json:"gpuMemoryUsed,omitempty"`
+	GPUMemoryTotal uint64          `json:"gpuMemoryTotal,omitempty"`
+	GPUs           []SlicerGPUStat `json:"gpus,omitempty"`
+}
+
+// IsStale reports whether the snapshot was taken more than maxAge ago. An
+// agent that has stopped sampling can keep serving its last snapshot with a
+// 200, so check this before alerting on the values. A nil snapshot or one
+// without a Timestamp is always stale.
+func (s *SlicerSnapshot) IsStale(maxAge time.Duration) bool {
+	if s == nil || s.Timestamp.IsZero() {
+		return true
+	}
+	return time.Since(s.Timestamp) > maxAge
+}
+
+// SlicerGPUStat represents metrics for a single GPU device within a VM.
+type SlicerGPUStat struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name,omitempty"`
+	Utilization float64 `json:"utilization"`
+	MemoryUsed  uint64  `json:"memoryUsed"`
+	MemoryTotal uint64  `json:"memoryTotal"`
 }
 
 // SlicerLogsResponse represents the response from the logs endpoint
@@ -240,6 +586,13 @@ type SlicerLogsResponse struct {
 	Content  string `json:"content"`
 }
 
+// SlicerDeleteVMOptions allows typed delete query params.
+type SlicerDeleteVMOptions struct {
+	// Force stops the VM immediately instead of asking the guest to shut
+	// down gracefully first. Use it for VMs that are stuck or unresponsive.
+	Force bool `json:"-"`
+}
+
 // SlicerDeleteResponse represents the response from the delete endpoint
 type SlicerDeleteResponse struct {
 	Message     string `json:"message"`
@@ -247,6 +600,62 @@ type SlicerDeleteResponse struct {
 	Error       string `json:"error"`
 }
 
+// DiskRemovedBytes returns the disk space freed by the delete, parsed from
+// DiskRemoved, so reclaimed space can be summed across deletes. Sizes may be
+// plain byte counts or human-readable, such as "12 GiB", "1.5G" or "512MB";
+// K, M, G and T are powers of 1024 with or without a "B" or "iB" suffix,
+// matching MiB and GiB. ok is false when DiskRemoved carries no size, e.g.
+// "true" from servers that only report whether a disk was removed.
+func (r *SlicerDeleteResponse) DiskRemovedBytes() (n int64, ok bool) {
+	n, err := parseByteSize(r.DiskRemoved)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseByteSize parses a byte count with an optional binary unit suffix.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == 0 || s == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	num, unit := s, ""
+	if i > 0 {
+		num, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit = strings.ToLower(unit)
+	if unit == "byte" || unit == "bytes" {
+		unit = ""
+	}
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "b"), "i")
+	var mult float64
+	switch unit {
+	case "":
+		mult = 1
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	case "g":
+		mult = 1 << 30
+	case "t":
+		mult = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+	return int64(v * mult), nil
+}
+
 type SlicerAgentHealthResponse struct {
 	// Hostname is the hostname of the agent
 	Hostname string `json:"hostname,omitempty"`
@@ -264,6 +673,80 @@ type SlicerAgentHealthResponse struct {
 	UserdataRan bool `json:"userdata_ran,omitempty"`
 }
 
+// MarshalJSON encodes AgentUptime and SystemUptime as duration strings
+// such as "72h3m", omitting them when zero.
+func (h SlicerAgentHealthResponse) MarshalJSON() ([]byte, error) {
+	type plain SlicerAgentHealthResponse
+	out := struct {
+		plain
+		AgentUptime  string `json:"agent_uptime,omitempty"`
+		SystemUptime string `json:"system_uptime,omitempty"`
+	}{plain: plain(h)}
+	if h.AgentUptime != 0 {
+		out.AgentUptime = formatDuration(h.AgentUptime)
+	}
+	if h.SystemUptime != 0 {
+		out.SystemUptime = formatDuration(h.SystemUptime)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes AgentUptime and SystemUptime from duration strings
+// as written by MarshalJSON, or from integer nanoseconds as sent by older
+// agents.
+func (h *SlicerAgentHealthResponse) UnmarshalJSON(data []byte) error {
+	type plain SlicerAgentHealthResponse
+	in := struct {
+		*plain
+		AgentUptime  json.RawMessage `json:"agent_uptime,omitempty"`
+		SystemUptime json.RawMessage `json:"system_uptime,omitempty"`
+	}{plain: (*plain)(h)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	agentUptime, err := parseJSONDuration(in.AgentUptime)
+	if err != nil {
+		return fmt.Errorf("agent_uptime: %w", err)
+	}
+	systemUptime, err := parseJSONDuration(in.SystemUptime)
+	if err != nil {
+		return fmt.Errorf("system_uptime: %w", err)
+	}
+	h.AgentUptime = agentUptime
+	h.SystemUptime = systemUptime
+	return nil
+}
+
+// formatDuration is time.Duration.String without trailing zero units, so
+// 72h3m0s is written as "72h3m".
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// parseJSONDuration reads a duration encoded either as a string accepted by
+// time.ParseDuration or as integer nanoseconds. An absent value is zero.
+func parseJSONDuration(raw json.RawMessage) (time.Duration, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return time.ParseDuration(s)
+	}
+	var n int64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, fmt.Errorf("invalid duration %s", raw)
+	}
+	return time.Duration(n), nil
+}
+
 // SlicerShutdownRequest contains parameters for shutting down or rebooting a VM.
 // Action can be "shutdown" (default) to halt the VM or "reboot" to restart it.
 type SlicerShutdownRequest struct {
@@ -23,6 +23,11 @@ type Secret struct {
 
 	// ModifiedAt is the time the secret was last modified
 	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+
+	// ETag identifies the current version of the secret, if the server
+	// provides one. Pass it as UpdateSecretRequest.IfMatch for a
+	// compare-and-swap update.
+	ETag string `json:"etag,omitempty"`
 }
 
 // CreateSecretRequest is the payload for creating a new secret via the REST API.
@@ -31,7 +36,8 @@ type CreateSecretRequest struct {
 	Name string `json:"name"`
 	// Data is the secret content
 	Data string `json:"data"`
-	// Permissions specifies the file permissions (defaults to system default)
+	// Permissions specifies the file permissions (defaults to system default),
+	// in any form accepted by ParsePermissions, e.g. "0600"
 	Permissions string `json:"permissions,omitempty"`
 
 	// GID is the user ID that should own the secret file. If not set, the default for
@@ -41,14 +47,25 @@ type CreateSecretRequest struct {
 	// GID is the group ID that should own the secret file. If not set, the default for
 	// a uint32 will be used i.e root.
 	GID uint32 `json:"gid,omitempty"`
+
+	// Compress gzips the request on the wire, for large secrets such as
+	// bundled configs or certificate chains. It only applies when
+	// GetCapabilities reports CompressedSecrets, so older servers are sent
+	// the request uncompressed.
+	Compress bool `json:"-"`
 }
 
 // UpdateSecretRequest is the payload for updating an existing secret via the REST API.
 // All fields are optional - only provided fields will be updated.
 type UpdateSecretRequest struct {
+	// IfMatch is sent as the If-Match header. When set, the update is
+	// rejected with ErrConflict unless it matches the secret's current ETag.
+	IfMatch string `json:"-"`
+
 	// Data is the updated secret content
 	Data string `json:"data"`
-	// Permissions specifies the file permissions
+	// Permissions specifies the file permissions, in any form accepted by
+	// ParsePermissions
 	Permissions string `json:"permissions,omitempty"`
 
 	// GID is the user ID that should own the secret file. If not set, the default for
@@ -58,4 +75,10 @@ type UpdateSecretRequest struct {
 	// GID is the group ID that should own the secret file. If not set, the default for
 	// a uint32 will be used i.e root.
 	GID uint32 `json:"gid,omitempty"`
+
+	// Compress gzips the request on the wire, for large secrets such as
+	// bundled configs or certificate chains. It only applies when
+	// GetCapabilities reports CompressedSecrets, so older servers are sent
+	// the request uncompressed.
+	Compress bool `json:"-"`
 }
@@ -0,0 +1,31 @@
+package slicer
+
+import "context"
+
+type userAgentSuffixKey struct{}
+
+// WithUserAgentSuffix returns a context that appends suffix to the client's
+// User-Agent for requests made with it, e.g. "op=create-vm" to break down
+// calls by operation in server-side analytics:
+//
+//	ctx := slicer.WithUserAgentSuffix(ctx, "op=create-vm")
+//	node, err := client.CreateVM(ctx, group, req)
+//
+// sends "my-tool/1.0; op=create-vm". The client-wide agent is kept as the
+// prefix so tools remain identifiable. Nested calls append in order. Has no
+// effect on clients created without a User-Agent.
+func WithUserAgentSuffix(ctx context.Context, suffix string) context.Context {
+	if prev, ok := ctx.Value(userAgentSuffixKey{}).(string); ok && prev != "" {
+		suffix = prev + "; " + suffix
+	}
+	return context.WithValue(ctx, userAgentSuffixKey{}, suffix)
+}
+
+// userAgentFor returns the client's User-Agent with any suffix carried by
+// ctx appended.
+func (c *SlicerClient) userAgentFor(ctx context.Context) string {
+	if suffix, ok := ctx.Value(userAgentSuffixKey{}).(string); ok && suffix != "" {
+		return c.userAgent + "; " + suffix
+	}
+	return c.userAgent
+}
@@ -0,0 +1,73 @@
+package slicer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateUserdata runs a client-side sanity check on userdata before it is
+// sent with CreateVM, so that mistakes surface immediately rather than after
+// the VM has booted.
+//
+// Userdata starting with "#cloud-config" is checked for the YAML mistakes
+// that most often break cloud-init: tab indentation, top-level lines that
+// are not "key: value" mappings, and duplicate top-level keys, of which
+// cloud-init silently keeps only the last. Scripts starting with "#!" are
+// rejected if they have Windows line endings, which make the interpreter
+// path unresolvable. Anything else, including empty userdata, is accepted
+// as-is.
+//
+// This is not a full YAML parser; a nil error does not guarantee that
+// cloud-init will accept the document.
+func ValidateUserdata(userdata string) error {
+	switch {
+	case strings.HasPrefix(userdata, "#cloud-config"):
+		return validateCloudConfig(userdata)
+	case strings.HasPrefix(userdata, "#!"):
+		if strings.Contains(userdata, "\r\n") {
+			return fmt.Errorf("userdata: script has Windows (CRLF) line endings")
+		}
+	}
+	return nil
+}
+
+func validateCloudConfig(doc string) error {
+	seen := map[string]int{}
+	// sequenceOK is set after a top-level key with no inline value, whose
+	// items may be written at column 0, as in "runcmd:\n- echo hi".
+	sequenceOK := false
+	for i, line := range strings.Split(doc, "\n") {
+		n := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent := line[:len(line)-len(trimmed)]; strings.Contains(indent, "\t") {
+			return fmt.Errorf("userdata: line %d: tabs are not allowed for indentation in YAML", n)
+		}
+		if len(trimmed) != len(line) || trimmed == "---" {
+			continue
+		}
+
+		if line == "-" || strings.HasPrefix(line, "- ") {
+			if sequenceOK {
+				continue
+			}
+			return fmt.Errorf("userdata: line %d: expected a top-level \"key: value\" mapping, got %q", n, line)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || key == "" {
+			return fmt.Errorf("userdata: line %d: expected a top-level \"key: value\" mapping, got %q", n, line)
+		}
+		value = strings.TrimSpace(value)
+		sequenceOK = value == "" || strings.HasPrefix(value, "#")
+		key = strings.TrimSpace(key)
+		if prev, dup := seen[key]; dup {
+			return fmt.Errorf("userdata: line %d: duplicate top-level key %q (first on line %d)", n, key, prev)
+		}
+		seen[key] = n
+	}
+	return nil
+}
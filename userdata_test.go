@@ -0,0 +1,77 @@
+package slicer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateUserdata(t *testing.T) {
+	tests := []struct {
+		name     string
+		userdata string
+		wantErr  string
+	}{
+		{name: "empty"},
+		{name: "script", userdata: "#!/bin/bash\necho hi\n"},
+		{name: "script with CRLF", userdata: "#!/bin/bash\r\necho hi\r\n", wantErr: "CRLF"},
+		{
+			name:     "valid cloud-config",
+			userdata: "#cloud-config\npackages:\n  - curl\nruncmd:\n  - |\n    echo hi\n",
+		},
+		{
+			name:     "tab indentation",
+			userdata: "#cloud-config\npackages:\n\t- curl\n",
+			wantErr:  "line 3: tabs",
+		},
+		{
+			name:     "not a mapping",
+			userdata: "#cloud-config\n- curl\n",
+			wantErr:  "line 2: expected",
+		},
+		{
+			name:     "compact sequences",
+			userdata: "#cloud-config\npackages:\n- nginx\n- curl\nruncmd:  # first boot\n- echo hi\n- [sh, -c, \"echo a: b\"]\nusers:\n- name: app\n  shell: /bin/bash\n",
+		},
+		{
+			name:     "compact sequence after scalar",
+			userdata: "#cloud-config\nhostname: vm-1\n- curl\n",
+			wantErr:  "line 3: expected",
+		},
+		{
+			name:     "duplicate key",
+			userdata: "#cloud-config\nruncmd:\n  - a\nruncmd:\n  - b\n",
+			wantErr:  `duplicate top-level key "runcmd" (first on line 2)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUserdata(tt.userdata)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Want no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Want error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateVMWithOptions_ValidateUserdataFailsBeforeRequest(t *testing.T) {
+	rt := &RecordingTransport{}
+	client := NewSlicerClient("http://slicer", "token", "test-agent", nil, WithRoundTripper(rt))
+
+	_, err := client.CreateVMWithOptions(context.Background(), "vm", SlicerCreateNodeRequest{
+		Userdata: "#cloud-config\npackages:\n\t- curl\n",
+	}, SlicerCreateNodeOptions{ValidateUserdata: true})
+	if err == nil {
+		t.Fatal("Want validation error")
+	}
+	if n := len(rt.Requests()); n != 0 {
+		t.Fatalf("Want no requests sent, got %d", n)
+	}
+}
@@ -0,0 +1,139 @@
+package slicer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RequireAgentVersion fetches the health of the agent in hostname and
+// returns an error wrapping ErrNotSupported if its version is older than
+// minVersion, e.g. "agent 0.3.0 < required 0.5.0". Versions are compared as
+// semantic versions; a leading "v" is accepted.
+func (c *SlicerClient) RequireAgentVersion(ctx context.Context, hostname, minVersion string) error {
+	want, err := parseSemver(minVersion)
+	if err != nil {
+		return fmt.Errorf("invalid required version %q: %w", minVersion, err)
+	}
+
+	health, err := c.GetAgentHealth(ctx, hostname, true)
+	if err != nil {
+		return err
+	}
+	if health.AgentVersion == "" {
+		return fmt.Errorf("agent on %s did not report a version, required %s: %w", hostname, minVersion, ErrNotSupported)
+	}
+
+	got, err := parseSemver(health.AgentVersion)
+	if err != nil {
+		return fmt.Errorf("agent on %s reported invalid version %q: %w", hostname, health.AgentVersion, err)
+	}
+
+	if got.compare(want) < 0 {
+		return fmt.Errorf("agent %s < required %s: %w", health.AgentVersion, minVersion, ErrNotSupported)
+	}
+	return nil
+}
+
+// semver is a parsed semantic version. Build metadata is dropped as it
+// does not affect precedence.
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+// parseSemver parses MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD] with an optional
+// leading "v".
+func parseSemver(v string) (semver, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var out semver
+	core := s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		core = s[:i]
+		out.pre = strings.Split(s[i+1:], ".")
+		for _, id := range out.pre {
+			if id == "" {
+				return semver{}, fmt.Errorf("empty pre-release identifier in %q", v)
+			}
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, fmt.Errorf("%q is not MAJOR.MINOR.PATCH", v)
+	}
+	nums := make([]uint64, 3)
+	for i, p := range parts {
+		if p == "" || (len(p) > 1 && p[0] == '0') {
+			return semver{}, fmt.Errorf("invalid version number %q in %q", p, v)
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return semver{}, fmt.Errorf("invalid version number %q in %q", p, v)
+		}
+		nums[i] = n
+	}
+	out.major, out.minor, out.patch = nums[0], nums[1], nums[2]
+	return out, nil
+}
+
+// compare returns -1, 0 or 1 following semver precedence rules.
+func (a semver) compare(b semver) int {
+	for _, d := range [][2]uint64{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A version without a pre-release has higher precedence.
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if c := comparePreID(a.pre[i], b.pre[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a.pre) < len(b.pre):
+		return -1
+	case len(a.pre) > len(b.pre):
+		return 1
+	}
+	return 0
+}
+
+// comparePreID compares pre-release identifiers: numeric identifiers
+// compare numerically and sort before alphanumeric ones.
+func comparePreID(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,63 @@
+package slicer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSemverCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.3.0", "0.5.0", -1},
+		{"0.10.0", "0.9.0", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.2", "1.0.0-alpha.10", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		a, err := parseSemver(tt.a)
+		if err != nil {
+			t.Fatalf("parseSemver(%q) error = %v", tt.a, err)
+		}
+		b, err := parseSemver(tt.b)
+		if err != nil {
+			t.Fatalf("parseSemver(%q) error = %v", tt.b, err)
+		}
+		if got := a.compare(b); got != tt.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"1.2", "1.2.x", "01.2.3", ""} {
+		if _, err := parseSemver(bad); err == nil {
+			t.Errorf("parseSemver(%q) want error", bad)
+		}
+	}
+}
+
+func TestRequireAgentVersion_TooOld(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"hostname":"vm-1","agent_version":"0.3.0"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlicerClient(server.URL, "token", "test-agent", nil)
+
+	err := client.RequireAgentVersion(context.Background(), "vm-1", "0.5.0")
+	if !errors.Is(err, ErrNotSupported) || !strings.Contains(err.Error(), "agent 0.3.0 < required 0.5.0") {
+		t.Fatalf("Want too-old error, got %v", err)
+	}
+	if err := client.RequireAgentVersion(context.Background(), "vm-1", "0.3.0"); err != nil {
+		t.Fatalf("Want nil for matching version, got %v", err)
+	}
+}
@@ -140,7 +140,7 @@ func (c *SlicerClient) WatchFS(ctx context.Context, vmName string, req SlicerFSW
 			return
 		}
 		if c.userAgent != "" {
-			httpReq.Header.Set("User-Agent", c.userAgent)
+			httpReq.Header.Set("User-Agent", c.userAgentFor(httpReq.Context()))
 		}
 		if c.token != "" {
 			httpReq.Header.Set("Authorization", "Bearer "+c.token)
@@ -150,7 +150,7 @@ func (c *SlicerClient) WatchFS(ctx context.Context, vmName string, req SlicerFSW
 			httpReq.Header.Set("Last-Event-ID", id)
 		}
 
-		res, err := c.httpClient.Do(httpReq)
+		res, err := c.do(httpReq)
 		if err != nil {
 			errs <- fmt.Errorf("failed to open watch stream: %w", err)
 			return
@@ -159,7 +159,7 @@ func (c *SlicerClient) WatchFS(ctx context.Context, vmName string, req SlicerFSW
 
 		if res.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(res.Body)
-			errs <- fmt.Errorf("watch request failed: %s - %s", res.Status, strings.TrimSpace(string(body)))
+			errs <- fmt.Errorf("watch request failed: %w", newAPIError(res, body))
 			return
 		}
 